@@ -0,0 +1,290 @@
+// Package ast defines the syntax tree the parser builds from Turtle source.
+package ast
+
+import (
+	"strings"
+
+	"turtle/lexer"
+)
+
+// Node is implemented by every node of the syntax tree.
+type Node interface {
+	Pos() lexer.Position // Position of the first token of the node
+	String() string      // Source form of the node
+}
+
+// Stmt is a node that can appear as a statement.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is a node that produces a value.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// AssignStmt assigns the value of an expression to a variable: Name = Value.
+type AssignStmt struct {
+	Name  lexer.Token
+	Value Expr
+}
+
+// IfStmt runs Then if Cond is true and Else, if present, otherwise:
+// if Cond then Then else Else, or if Cond { ... } else { ... }.
+type IfStmt struct {
+	If   lexer.Token
+	Cond Expr
+	Then Stmt
+	Else Stmt // nil if there is no else branch
+}
+
+// IndexAssignStmt assigns to an element of a list: Target = Value.
+type IndexAssignStmt struct {
+	Target *IndexExpr
+	Value  Expr
+}
+
+// WhileStmt runs Body for as long as Cond is true: while Cond { Body }.
+type WhileStmt struct {
+	While lexer.Token
+	Cond  Expr
+	Body  *BlockStmt
+}
+
+// SleepStmt pauses for Duration milliseconds: sleep Duration.
+type SleepStmt struct {
+	Sleep    lexer.Token
+	Duration Expr
+}
+
+// FuncStmt defines a function: fn Name(Params) { Body }.
+type FuncStmt struct {
+	Fn     lexer.Token
+	Name   lexer.Token
+	Params []lexer.Token
+	Body   *BlockStmt
+}
+
+// ReturnStmt ends the function being called: return Value.
+type ReturnStmt struct {
+	Return lexer.Token
+	Value  Expr // nil if no value is returned
+}
+
+// ImportStmt runs another file so that its definitions can be used:
+// import Path.
+type ImportStmt struct {
+	Import lexer.Token
+	Path   *StringLit
+}
+
+// ExprStmt evaluates an expression and prints its value.
+type ExprStmt struct {
+	Expr Expr
+}
+
+// BlockStmt is a brace-delimited sequence of statements: { Stmts }.
+type BlockStmt struct {
+	Lbrace lexer.Token
+	Stmts  []Stmt
+}
+
+// IntLit is a whole number literal such as 42.
+type IntLit struct {
+	Token lexer.Token
+	Value int64
+}
+
+// FloatLit is a decimal number literal such as 3.14.
+type FloatLit struct {
+	Token lexer.Token
+	Value float64
+}
+
+// BoolLit is one of the literals true and false.
+type BoolLit struct {
+	Token lexer.Token
+	Value bool
+}
+
+// StringLit is a string literal such as "hello", whose Value has its
+// escape sequences resolved.
+type StringLit struct {
+	Token lexer.Token
+	Value string
+}
+
+// Ident is a reference to a variable.
+type Ident struct {
+	Token lexer.Token
+}
+
+// ParenExpr is an expression in parentheses: (Inner).
+type ParenExpr struct {
+	Lparen lexer.Token
+	Inner  Expr
+}
+
+// UnaryExpr applies a sign or logical not to its operand: Op Operand.
+type UnaryExpr struct {
+	Op      lexer.Token
+	Operand Expr
+}
+
+// BinaryExpr applies an arithmetic, comparison or logical operator:
+// Left Op Right.
+type BinaryExpr struct {
+	Op    lexer.Token
+	Left  Expr
+	Right Expr
+}
+
+// ListLit is a list literal: [Elems].
+type ListLit struct {
+	Lbrack lexer.Token
+	Elems  []Expr
+}
+
+// IndexExpr reads an element of a list: Target[Index].
+type IndexExpr struct {
+	Target Expr
+	Lbrack lexer.Token
+	Index  Expr
+}
+
+// CallExpr calls a function with arguments: Callee(Args).
+type CallExpr struct {
+	Callee Expr
+	Lparen lexer.Token
+	Args   []Expr
+}
+
+// LetExpr binds Name to Value while Body is evaluated:
+// let Name = Value in Body.
+type LetExpr struct {
+	Let   lexer.Token
+	Name  lexer.Token
+	Value Expr
+	Body  Expr
+}
+
+func (s *AssignStmt) Pos() lexer.Position      { return s.Name.Pos }
+func (s *IndexAssignStmt) Pos() lexer.Position { return s.Target.Pos() }
+func (s *IfStmt) Pos() lexer.Position          { return s.If.Pos }
+func (s *WhileStmt) Pos() lexer.Position       { return s.While.Pos }
+func (s *SleepStmt) Pos() lexer.Position       { return s.Sleep.Pos }
+func (s *FuncStmt) Pos() lexer.Position        { return s.Fn.Pos }
+func (s *ReturnStmt) Pos() lexer.Position      { return s.Return.Pos }
+func (s *ImportStmt) Pos() lexer.Position      { return s.Import.Pos }
+func (s *ExprStmt) Pos() lexer.Position        { return s.Expr.Pos() }
+func (s *BlockStmt) Pos() lexer.Position       { return s.Lbrace.Pos }
+func (e *IntLit) Pos() lexer.Position          { return e.Token.Pos }
+func (e *FloatLit) Pos() lexer.Position        { return e.Token.Pos }
+func (e *BoolLit) Pos() lexer.Position         { return e.Token.Pos }
+func (e *StringLit) Pos() lexer.Position       { return e.Token.Pos }
+func (e *Ident) Pos() lexer.Position           { return e.Token.Pos }
+func (e *ParenExpr) Pos() lexer.Position       { return e.Lparen.Pos }
+func (e *UnaryExpr) Pos() lexer.Position       { return e.Op.Pos }
+func (e *BinaryExpr) Pos() lexer.Position      { return e.Left.Pos() }
+func (e *ListLit) Pos() lexer.Position         { return e.Lbrack.Pos }
+func (e *IndexExpr) Pos() lexer.Position       { return e.Target.Pos() }
+func (e *CallExpr) Pos() lexer.Position        { return e.Callee.Pos() }
+func (e *LetExpr) Pos() lexer.Position         { return e.Let.Pos }
+
+func (s *AssignStmt) String() string      { return s.Name.Value + " = " + s.Value.String() }
+func (s *IndexAssignStmt) String() string { return s.Target.String() + " = " + s.Value.String() }
+func (s *WhileStmt) String() string       { return "while " + s.Cond.String() + " " + s.Body.String() }
+func (s *SleepStmt) String() string       { return "sleep " + s.Duration.String() }
+func (s *ImportStmt) String() string      { return "import " + s.Path.String() }
+func (s *ExprStmt) String() string        { return s.Expr.String() }
+func (e *IntLit) String() string          { return e.Token.Value }
+func (e *FloatLit) String() string        { return e.Token.Value }
+func (e *BoolLit) String() string         { return e.Token.Value }
+func (e *StringLit) String() string       { return e.Token.Value }
+func (e *Ident) String() string           { return e.Token.Value }
+func (e *ParenExpr) String() string       { return "(" + e.Inner.String() + ")" }
+func (e *UnaryExpr) String() string       { return e.Op.Value + e.Operand.String() }
+func (e *IndexExpr) String() string       { return e.Target.String() + "[" + e.Index.String() + "]" }
+
+func (s *IfStmt) String() string {
+	var sb strings.Builder
+	sb.WriteString("if " + s.Cond.String() + " then " + s.Then.String())
+	if s.Else != nil {
+		sb.WriteString(" else " + s.Else.String())
+	}
+	return sb.String()
+}
+
+func (s *BlockStmt) String() string {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for _, stmt := range s.Stmts {
+		sb.WriteString(stmt.String() + "\n")
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (s *FuncStmt) String() string {
+	params := make([]string, len(s.Params))
+	for i, param := range s.Params {
+		params[i] = param.Value
+	}
+	return "fn " + s.Name.Value + "(" + strings.Join(params, ", ") + ") " + s.Body.String()
+}
+
+func (s *ReturnStmt) String() string {
+	if s.Value == nil {
+		return "return"
+	}
+	return "return " + s.Value.String()
+}
+
+func (e *ListLit) String() string {
+	elems := make([]string, len(e.Elems))
+	for i, elem := range e.Elems {
+		elems[i] = elem.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+func (e *CallExpr) String() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return e.Callee.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (e *BinaryExpr) String() string {
+	return e.Left.String() + " " + e.Op.Value + " " + e.Right.String()
+}
+
+func (e *LetExpr) String() string {
+	return "let " + e.Name.Value + " = " + e.Value.String() + " in " + e.Body.String()
+}
+
+func (*AssignStmt) stmtNode()      {}
+func (*IndexAssignStmt) stmtNode() {}
+func (*IfStmt) stmtNode()          {}
+func (*WhileStmt) stmtNode()       {}
+func (*SleepStmt) stmtNode()       {}
+func (*FuncStmt) stmtNode()        {}
+func (*ReturnStmt) stmtNode()      {}
+func (*ImportStmt) stmtNode()      {}
+func (*ExprStmt) stmtNode()        {}
+func (*BlockStmt) stmtNode()       {}
+func (*IntLit) exprNode()          {}
+func (*FloatLit) exprNode()        {}
+func (*BoolLit) exprNode()         {}
+func (*StringLit) exprNode()       {}
+func (*Ident) exprNode()           {}
+func (*ParenExpr) exprNode()       {}
+func (*UnaryExpr) exprNode()       {}
+func (*BinaryExpr) exprNode()      {}
+func (*ListLit) exprNode()         {}
+func (*IndexExpr) exprNode()       {}
+func (*CallExpr) exprNode()        {}
+func (*LetExpr) exprNode()         {}
@@ -0,0 +1,125 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fprint writes node to w as an indented tree, one node per line with its
+// children indented beneath it, followed by the position it starts at:
+//
+//	AssignStmt x  (line 1, col 1)
+//	  BinaryExpr *  (line 1, col 5)
+//	    IntLit 2  (line 1, col 5)
+//	    ...
+func Fprint(w io.Writer, node Node) error {
+	d := &dumper{w: w}
+	d.node(node)
+	return d.err
+}
+
+// dumper writes the lines of a tree, keeping the first write error.
+type dumper struct {
+	w     io.Writer
+	depth int
+	err   error
+}
+
+// line writes one node's line at the current depth.
+func (d *dumper) line(node Node, label string) {
+	if d.err != nil {
+		return
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	if label != "" {
+		name += " " + label
+	}
+	_, d.err = fmt.Fprintf(d.w, "%s%s  (%s)\n", strings.Repeat("  ", d.depth), name, node.Pos())
+}
+
+// node writes a node's line followed by its children.
+func (d *dumper) node(node Node) {
+	switch n := node.(type) {
+	case *AssignStmt:
+		d.line(n, n.Name.Value)
+		d.children(n.Value)
+	case *IndexAssignStmt:
+		d.line(n, "")
+		d.children(n.Target, n.Value)
+	case *IfStmt:
+		d.line(n, "")
+		if n.Else == nil {
+			d.children(n.Cond, n.Then)
+		} else {
+			d.children(n.Cond, n.Then, n.Else)
+		}
+	case *WhileStmt:
+		d.line(n, "")
+		d.children(n.Cond, n.Body)
+	case *SleepStmt:
+		d.line(n, "")
+		d.children(n.Duration)
+	case *FuncStmt:
+		params := make([]string, len(n.Params))
+		for i, param := range n.Params {
+			params[i] = param.Value
+		}
+		d.line(n, n.Name.Value+"("+strings.Join(params, ", ")+")")
+		d.children(n.Body)
+	case *ReturnStmt:
+		d.line(n, "")
+		if n.Value != nil {
+			d.children(n.Value)
+		}
+	case *ImportStmt:
+		d.line(n, n.Path.String())
+	case *ExprStmt:
+		d.line(n, "")
+		d.children(n.Expr)
+	case *BlockStmt:
+		d.line(n, "")
+		for _, stmt := range n.Stmts {
+			d.children(stmt)
+		}
+	case *IntLit, *FloatLit, *BoolLit, *StringLit, *Ident:
+		d.line(n, n.String())
+	case *ParenExpr:
+		d.line(n, "")
+		d.children(n.Inner)
+	case *UnaryExpr:
+		d.line(n, n.Op.Value)
+		d.children(n.Operand)
+	case *BinaryExpr:
+		d.line(n, n.Op.Value)
+		d.children(n.Left, n.Right)
+	case *ListLit:
+		d.line(n, "")
+		for _, elem := range n.Elems {
+			d.children(elem)
+		}
+	case *IndexExpr:
+		d.line(n, "")
+		d.children(n.Target, n.Index)
+	case *CallExpr:
+		d.line(n, "")
+		d.children(n.Callee)
+		for _, arg := range n.Args {
+			d.children(arg)
+		}
+	case *LetExpr:
+		d.line(n, n.Name.Value)
+		d.children(n.Value, n.Body)
+	default:
+		d.line(n, "")
+	}
+}
+
+// children writes nodes one level deeper than the current one.
+func (d *dumper) children(nodes ...Node) {
+	d.depth++
+	for _, node := range nodes {
+		d.node(node)
+	}
+	d.depth--
+}
@@ -0,0 +1,65 @@
+package ast_test
+
+import (
+	"bytes"
+	"testing"
+
+	"turtle/ast"
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+func TestFprint(t *testing.T) {
+	stmt, err := parser.NewParser(lexer.NewLexerFromString("x = 2 * (3 + 4)")).ParseStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ast.Fprint(&buf, stmt); err != nil {
+		t.Fatal(err)
+	}
+	want := `AssignStmt x  (line 1, col 1)
+  BinaryExpr *  (line 1, col 5)
+    IntLit 2  (line 1, col 5)
+    ParenExpr  (line 1, col 9)
+      BinaryExpr +  (line 1, col 10)
+        IntLit 3  (line 1, col 10)
+        IntLit 4  (line 1, col 14)
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestFprintStatements(t *testing.T) {
+	src := "fn f(a, b) {\n  if a < b then return [a, b][0] else return -b\n}"
+	stmt, err := parser.NewParser(lexer.NewLexerFromString(src)).ParseStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ast.Fprint(&buf, stmt); err != nil {
+		t.Fatal(err)
+	}
+	want := `FuncStmt f(a, b)  (line 1, col 1)
+  BlockStmt  (line 1, col 12)
+    IfStmt  (line 2, col 3)
+      BinaryExpr <  (line 2, col 6)
+        Ident a  (line 2, col 6)
+        Ident b  (line 2, col 10)
+      ReturnStmt  (line 2, col 17)
+        IndexExpr  (line 2, col 24)
+          ListLit  (line 2, col 24)
+            Ident a  (line 2, col 25)
+            Ident b  (line 2, col 28)
+          IntLit 0  (line 2, col 31)
+      ReturnStmt  (line 2, col 39)
+        UnaryExpr -  (line 2, col 46)
+          Ident b  (line 2, col 47)
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -1 +1 @@
-go build -o build src/main.go 
\ No newline at end of file
+go build -o build/main.exe ./src
\ No newline at end of file
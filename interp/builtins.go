@@ -0,0 +1,164 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"turtle/lexer"
+)
+
+// builtins are the functions every interpreter starts out with.
+var builtins = []*Builtin{
+	{Name: "print", Fn: builtinPrint},
+	{Name: "len", Fn: builtinLen},
+	{Name: "push", Fn: builtinPush},
+	{Name: "assert", Fn: builtinAssert},
+	{Name: "forward", Fn: builtinMove("forward", 1)},
+	{Name: "back", Fn: builtinMove("back", -1)},
+	{Name: "left", Fn: builtinTurn("left", -1)},
+	{Name: "right", Fn: builtinTurn("right", 1)},
+	{Name: "penup", Fn: builtinPen("penup", false)},
+	{Name: "pendown", Fn: builtinPen("pendown", true)},
+	{Name: "color", Fn: builtinColor},
+}
+
+// checkArgs reports an error at pos unless exactly want arguments were
+// passed to the builtin.
+func checkArgs(name string, pos lexer.Position, args []Value, want int) error {
+	if len(args) != want {
+		return &RuntimeError{Pos: pos, Msg: fmt.Sprintf("%s expects %d arguments, got %d", name, want, len(args))}
+	}
+	return nil
+}
+
+// builtinPrint prints its arguments separated by spaces, followed by a
+// newline, and returns nil.
+func builtinPrint(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.String()
+	}
+	fmt.Fprintln(interp.Out, strings.Join(parts, " "))
+	return Nil{}, nil
+}
+
+// builtinLen returns the number of elements in a list or characters in a
+// string.
+func builtinLen(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+	if err := checkArgs("len", pos, args, 1); err != nil {
+		return nil, err
+	}
+	switch arg := args[0].(type) {
+	case *List:
+		return Int(len(arg.Elems)), nil
+	case String:
+		return Int(utf8.RuneCountInString(string(arg))), nil
+	default:
+		return nil, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("type mismatch: expected list or string, got %s", arg.Kind())}
+	}
+}
+
+// builtinPush appends a value to the end of a list and returns nil.
+func builtinPush(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+	if err := checkArgs("push", pos, args, 2); err != nil {
+		return nil, err
+	}
+	list, ok := args[0].(*List)
+	if !ok {
+		return nil, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("type mismatch: expected list, got %s", args[0].Kind())}
+	}
+	list.Elems = append(list.Elems, args[1])
+	return Nil{}, nil
+}
+
+// builtinAssert reports an error at the call, with the message it is passed
+// if any, unless its condition counts as true. It returns nil.
+func builtinAssert(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("assert expects 1 or 2 arguments, got %d", len(args))}
+	}
+	if isTruthy(args[0]) {
+		return Nil{}, nil
+	}
+	msg := "assertion failed"
+	if len(args) == 2 {
+		msg += ": " + args[1].String()
+	}
+	return nil, &RuntimeError{Pos: pos, Msg: msg, Err: ErrAssertionFailed}
+}
+
+// expectFinite returns value as a number for the builtin name, reporting an
+// error at pos if it is not a number or is infinite or NaN, which the turtle
+// could not be moved by.
+func expectFinite(name string, pos lexer.Position, value Value) (float64, error) {
+	number, err := expectNumber(pos, value)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("%s expects a finite number, got %s", name, value)}
+	}
+	return number, nil
+}
+
+// builtinMove returns a builtin that moves the turtle the number of steps it
+// is passed, forwards if sign is 1 and backwards if it is -1.
+func builtinMove(name string, sign float64) func(*Interpreter, lexer.Position, []Value) (Value, error) {
+	return func(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+		if err := checkArgs(name, pos, args, 1); err != nil {
+			return nil, err
+		}
+		distance, err := expectFinite(name, pos, args[0])
+		if err != nil {
+			return nil, err
+		}
+		interp.Turtle.Forward(sign * distance)
+		return Nil{}, nil
+	}
+}
+
+// builtinTurn returns a builtin that turns the turtle the number of degrees
+// it is passed, clockwise if sign is 1 and anticlockwise if it is -1.
+func builtinTurn(name string, sign float64) func(*Interpreter, lexer.Position, []Value) (Value, error) {
+	return func(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+		if err := checkArgs(name, pos, args, 1); err != nil {
+			return nil, err
+		}
+		degrees, err := expectFinite(name, pos, args[0])
+		if err != nil {
+			return nil, err
+		}
+		interp.Turtle.Turn(sign * degrees)
+		return Nil{}, nil
+	}
+}
+
+// builtinPen returns a builtin that lifts the turtle's pen or puts it down.
+func builtinPen(name string, down bool) func(*Interpreter, lexer.Position, []Value) (Value, error) {
+	return func(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+		if err := checkArgs(name, pos, args, 0); err != nil {
+			return nil, err
+		}
+		interp.Turtle.PenDown = down
+		return Nil{}, nil
+	}
+}
+
+// builtinColor sets the color of the lines the turtle draws from now on, by
+// name or as "#rrggbb".
+func builtinColor(interp *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+	if err := checkArgs("color", pos, args, 1); err != nil {
+		return nil, err
+	}
+	name, ok := args[0].(String)
+	if !ok {
+		return nil, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("type mismatch: expected string, got %s", args[0].Kind())}
+	}
+	if _, ok := parseColor(string(name)); !ok {
+		return nil, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("unknown color %q", string(name))}
+	}
+	interp.Turtle.Color = string(name)
+	return Nil{}, nil
+}
@@ -0,0 +1,284 @@
+package interp
+
+import (
+	"fmt"
+
+	"turtle/ast"
+	"turtle/lexer"
+)
+
+// opcode is the operation of a bytecode instruction.
+type opcode byte
+
+const (
+	opConst       opcode = iota // Push constants[Arg]
+	opGet                       // Push the variable named by tokens[Arg]
+	opAssign                    // Pop a value and assign it to the variable named by tokens[Arg]
+	opDefine                    // Pop a value and define the variable named by tokens[Arg] in the innermost scope
+	opPushScope                 // Enter a new innermost scope
+	opPopScope                  // Leave the innermost scope
+	opUnary                     // Replace the top value with the result of the operator tokens[Arg]
+	opBinary                    // Pop two values and push the result of the operator tokens[Arg]
+	opTruthy                    // Replace the top value with whether it counts as true
+	opJump                      // Continue at instruction Arg
+	opJumpIfFalse               // Pop a value and continue at instruction Arg unless it counts as true
+	opJumpIfTrue                // Pop a value and continue at instruction Arg if it counts as true
+	opList                      // Pop Arg values and push a list of them
+	opIndex                     // Pop an index and a list and push the element, for the expression indexes[Arg]
+	opSetIndex                  // Pop a value, an index and a list and set the element, for the expression indexes[Arg]
+	opCall                      // Pop the arguments and the callee of calls[Arg] and push the result
+	opFunc                      // Define the function funcs[Arg] in the innermost scope
+	opReturn                    // Pop a value and return it from the chunk
+	opPrint                     // Pop a value and print it, unless it is nil
+	opSleep                     // Pop a number and pause for that many milliseconds
+	opStep                      // Count a statement or loop iteration against MaxSteps
+	opImport                    // Run the file of imports[Arg] unless it was imported already
+)
+
+// instruction is one bytecode instruction, remembering the position of the
+// source it was compiled from for error messages.
+type instruction struct {
+	Op  opcode
+	Arg int
+	Pos lexer.Position
+}
+
+// chunk is the bytecode of one statement or function body, together with the
+// tables its instructions refer to.
+type chunk struct {
+	code      []instruction
+	constants []Value
+	tokens    []lexer.Token // Variable names and operators
+	sources   []string      // Source of the value assigned by the instruction referring to the same token
+	indexes   []*ast.IndexExpr
+	calls     []*ast.CallExpr
+	funcs     []*ast.FuncStmt
+	imports   []*ast.ImportStmt
+}
+
+// emit appends an instruction and returns its address.
+func (c *chunk) emit(op opcode, arg int, pos lexer.Position) int {
+	c.code = append(c.code, instruction{Op: op, Arg: arg, Pos: pos})
+	return len(c.code) - 1
+}
+
+// patch points the jump at address to the next instruction to be emitted.
+func (c *chunk) patch(address int) {
+	c.code[address].Arg = len(c.code)
+}
+
+// addConstant adds a value to the constants pool and returns its index.
+func (c *chunk) addConstant(value Value) int {
+	c.constants = append(c.constants, value)
+	return len(c.constants) - 1
+}
+
+// addToken adds a token, and the source of the value assigned with it if any,
+// and returns its index.
+func (c *chunk) addToken(token lexer.Token, source string) int {
+	c.tokens = append(c.tokens, token)
+	c.sources = append(c.sources, source)
+	return len(c.tokens) - 1
+}
+
+// compile lowers a statement to bytecode.
+func compile(stmt ast.Stmt) (*chunk, error) {
+	c := &chunk{}
+	if err := c.compileStmt(stmt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// compileBody lowers a function body to bytecode that returns nil if it runs
+// off the end.
+func compileBody(fn *ast.FuncStmt) (*chunk, error) {
+	c := &chunk{}
+	if err := c.compileStmt(fn.Body); err != nil {
+		return nil, err
+	}
+	c.emit(opConst, c.addConstant(Nil{}), fn.Body.Pos())
+	c.emit(opReturn, 0, fn.Body.Pos())
+	return c, nil
+}
+
+// compileStmt appends the bytecode of a statement.
+func (c *chunk) compileStmt(stmt ast.Stmt) error {
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		if err := c.compileExpr(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(opAssign, c.addToken(stmt.Name, stmt.Value.String()), stmt.Pos())
+	case *ast.IndexAssignStmt:
+		if err := c.compileExpr(stmt.Target.Target); err != nil {
+			return err
+		}
+		if err := c.compileExpr(stmt.Target.Index); err != nil {
+			return err
+		}
+		if err := c.compileExpr(stmt.Value); err != nil {
+			return err
+		}
+		c.indexes = append(c.indexes, stmt.Target)
+		c.emit(opSetIndex, len(c.indexes)-1, stmt.Pos())
+	case *ast.IfStmt:
+		if err := c.compileExpr(stmt.Cond); err != nil {
+			return err
+		}
+		skipThen := c.emit(opJumpIfFalse, 0, stmt.Pos())
+		if err := c.compileStmt(stmt.Then); err != nil {
+			return err
+		}
+		if stmt.Else == nil {
+			c.patch(skipThen)
+			return nil
+		}
+		skipElse := c.emit(opJump, 0, stmt.Pos())
+		c.patch(skipThen)
+		if err := c.compileStmt(stmt.Else); err != nil {
+			return err
+		}
+		c.patch(skipElse)
+	case *ast.WhileStmt:
+		start := len(c.code)
+		if err := c.compileExpr(stmt.Cond); err != nil {
+			return err
+		}
+		exit := c.emit(opJumpIfFalse, 0, stmt.Pos())
+		c.emit(opStep, 0, stmt.Pos())
+		if err := c.compileStmt(stmt.Body); err != nil {
+			return err
+		}
+		c.emit(opJump, start, stmt.Pos())
+		c.patch(exit)
+	case *ast.SleepStmt:
+		if err := c.compileExpr(stmt.Duration); err != nil {
+			return err
+		}
+		c.emit(opSleep, 0, stmt.Duration.Pos())
+	case *ast.FuncStmt:
+		c.funcs = append(c.funcs, stmt)
+		c.emit(opFunc, len(c.funcs)-1, stmt.Pos())
+	case *ast.ReturnStmt:
+		if stmt.Value == nil {
+			c.emit(opConst, c.addConstant(Nil{}), stmt.Pos())
+		} else if err := c.compileExpr(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(opReturn, 0, stmt.Pos())
+	case *ast.ImportStmt:
+		c.imports = append(c.imports, stmt)
+		c.emit(opImport, len(c.imports)-1, stmt.Pos())
+	case *ast.ExprStmt:
+		if err := c.compileExpr(stmt.Expr); err != nil {
+			return err
+		}
+		c.emit(opPrint, 0, stmt.Pos())
+	case *ast.BlockStmt:
+		c.emit(opPushScope, 0, stmt.Pos())
+		for _, inner := range stmt.Stmts {
+			c.emit(opStep, 0, inner.Pos())
+			if err := c.compileStmt(inner); err != nil {
+				return err
+			}
+		}
+		c.emit(opPopScope, 0, stmt.Pos())
+	default:
+		return &RuntimeError{Pos: stmt.Pos(), Msg: fmt.Sprintf("cannot compile %T", stmt)}
+	}
+	return nil
+}
+
+// compileExpr appends the bytecode of an expression, which leaves its value
+// on the stack.
+func (c *chunk) compileExpr(expr ast.Expr) error {
+	switch expr := expr.(type) {
+	case *ast.IntLit:
+		c.emit(opConst, c.addConstant(Int(expr.Value)), expr.Pos())
+	case *ast.FloatLit:
+		c.emit(opConst, c.addConstant(Float(expr.Value)), expr.Pos())
+	case *ast.BoolLit:
+		c.emit(opConst, c.addConstant(Bool(expr.Value)), expr.Pos())
+	case *ast.StringLit:
+		c.emit(opConst, c.addConstant(String(expr.Value)), expr.Pos())
+	case *ast.Ident:
+		c.emit(opGet, c.addToken(expr.Token, ""), expr.Pos())
+	case *ast.ParenExpr:
+		return c.compileExpr(expr.Inner)
+	case *ast.UnaryExpr:
+		if err := c.compileExpr(expr.Operand); err != nil {
+			return err
+		}
+		c.emit(opUnary, c.addToken(expr.Op, ""), expr.Op.Pos)
+	case *ast.BinaryExpr:
+		if err := c.compileExpr(expr.Left); err != nil {
+			return err
+		}
+
+		// The logical operators only evaluate their right operand if the
+		// left one does not already decide the result
+		switch expr.Op.Type {
+		case "AND", "OR":
+			jump := opJumpIfFalse
+			if expr.Op.Type == "OR" {
+				jump = opJumpIfTrue
+			}
+			decided := c.emit(jump, 0, expr.Op.Pos)
+			if err := c.compileExpr(expr.Right); err != nil {
+				return err
+			}
+			c.emit(opTruthy, 0, expr.Op.Pos)
+			end := c.emit(opJump, 0, expr.Op.Pos)
+			c.patch(decided)
+			c.emit(opConst, c.addConstant(Bool(expr.Op.Type == "OR")), expr.Op.Pos)
+			c.patch(end)
+			return nil
+		}
+
+		if err := c.compileExpr(expr.Right); err != nil {
+			return err
+		}
+		c.emit(opBinary, c.addToken(expr.Op, ""), expr.Op.Pos)
+	case *ast.ListLit:
+		for _, elem := range expr.Elems {
+			if err := c.compileExpr(elem); err != nil {
+				return err
+			}
+		}
+		c.emit(opList, len(expr.Elems), expr.Pos())
+	case *ast.IndexExpr:
+		if err := c.compileExpr(expr.Target); err != nil {
+			return err
+		}
+		if err := c.compileExpr(expr.Index); err != nil {
+			return err
+		}
+		c.indexes = append(c.indexes, expr)
+		c.emit(opIndex, len(c.indexes)-1, expr.Lbrack.Pos)
+	case *ast.CallExpr:
+		if err := c.compileExpr(expr.Callee); err != nil {
+			return err
+		}
+		for _, arg := range expr.Args {
+			if err := c.compileExpr(arg); err != nil {
+				return err
+			}
+		}
+		c.calls = append(c.calls, expr)
+		c.emit(opCall, len(c.calls)-1, expr.Lparen.Pos)
+	case *ast.LetExpr:
+		if err := c.compileExpr(expr.Value); err != nil {
+			return err
+		}
+		c.emit(opPushScope, 0, expr.Pos())
+		c.emit(opDefine, c.addToken(expr.Name, expr.Value.String()), expr.Pos())
+		if err := c.compileExpr(expr.Body); err != nil {
+			return err
+		}
+		c.emit(opPopScope, 0, expr.Pos())
+	default:
+		return &RuntimeError{Pos: expr.Pos(), Msg: fmt.Sprintf("cannot compile %T", expr)}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package interp
+
+// Environment is one scope of variables. Each block and let expression gets
+// an environment of its own, whose outer environment is the scope it appears
+// in, so variables defined inside a block are gone once the block ends.
+type Environment struct {
+	values  map[string]Value  // Map of variable name to variable value
+	sources map[string]string // Map of variable name to the source of its last assignment
+	outer   *Environment      // Enclosing scope, nil for the global scope
+}
+
+// NewEnvironment creates an empty scope nested inside outer, which is nil for
+// the global scope.
+func NewEnvironment(outer *Environment) *Environment {
+	return &Environment{
+		values:  make(map[string]Value),
+		sources: make(map[string]string),
+		outer:   outer,
+	}
+}
+
+// Get returns the value of the named variable from the innermost scope that
+// defines it.
+func (env *Environment) Get(name string) (Value, bool) {
+	for scope := env; scope != nil; scope = scope.outer {
+		if value, ok := scope.values[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Source returns the source of the expression the named variable was last
+// assigned from, or "" if it is not defined.
+func (env *Environment) Source(name string) string {
+	for scope := env; scope != nil; scope = scope.outer {
+		if _, ok := scope.values[name]; ok {
+			return scope.sources[name]
+		}
+	}
+	return ""
+}
+
+// Define creates or overwrites a variable in this scope, shadowing any
+// variable of the same name in the outer scopes.
+func (env *Environment) Define(name string, value Value, source string) {
+	env.values[name] = value
+	env.sources[name] = source
+}
+
+// Assign updates the named variable in the innermost scope that defines it,
+// or defines it in this scope if no scope does.
+func (env *Environment) Assign(name string, value Value, source string) {
+	for scope := env; scope != nil; scope = scope.outer {
+		if _, ok := scope.values[name]; ok {
+			scope.Define(name, value, source)
+			return
+		}
+	}
+	env.Define(name, value, source)
+}
@@ -0,0 +1,44 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+
+	"turtle/lexer"
+)
+
+var (
+	// ErrDivisionByZero is wrapped by the RuntimeError reported when an
+	// expression divides by zero.
+	ErrDivisionByZero = errors.New("division by zero")
+
+	// ErrUndefinedVariable is wrapped by the RuntimeError reported when an
+	// expression reads a variable that was never assigned.
+	ErrUndefinedVariable = errors.New("undefined variable")
+
+	// ErrAssertionFailed is wrapped by the RuntimeError reported when the
+	// condition passed to assert is false.
+	ErrAssertionFailed = errors.New("assertion failed")
+
+	// ErrStepLimitExceeded is wrapped by the RuntimeError reported when a Run
+	// takes more than MaxSteps steps.
+	ErrStepLimitExceeded = errors.New("step limit exceeded")
+)
+
+// RuntimeError reports a failure while evaluating a well-formed statement,
+// such as division by zero or a reference to an undefined variable.
+type RuntimeError struct {
+	Pos lexer.Position
+	Msg string
+	Err error // Sentinel error the failure is an instance of, if any
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("runtime error at %s: %s", e.Pos, e.Msg)
+}
+
+// Position returns where the error occurred.
+func (e *RuntimeError) Position() lexer.Position { return e.Pos }
+
+// Unwrap returns the sentinel error, so callers can use errors.Is.
+func (e *RuntimeError) Unwrap() error { return e.Err }
@@ -0,0 +1,79 @@
+package interp
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+func TestErrorCategories(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // "lex", "parse" or "runtime"
+		pos  lexer.Position
+	}{
+		{src: "$", want: "lex", pos: lexer.Position{Line: 1, Column: 1}},
+		{src: `"open`, want: "lex", pos: lexer.Position{Line: 1, Column: 1}},
+		{src: "(1 +", want: "parse", pos: lexer.Position{Line: 1, Column: 5}},
+		{src: "x = = 1", want: "parse", pos: lexer.Position{Line: 1, Column: 5}},
+		{src: "1 / 0", want: "runtime", pos: lexer.Position{Line: 1, Column: 3}},
+		{src: "\ny + 1", want: "runtime", pos: lexer.Position{Line: 2, Column: 1}},
+	}
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.Out = io.Discard
+		_, err := interp.EvalString(test.src)
+		if err == nil {
+			t.Errorf("%q: got no error, want a %s error", test.src, test.want)
+			continue
+		}
+
+		var lexErr *lexer.LexError
+		var parseErr *parser.ParseError
+		var runtimeErr *RuntimeError
+		got := ""
+		switch {
+		case errors.As(err, &lexErr):
+			got = "lex"
+		case errors.As(err, &parseErr):
+			got = "parse"
+		case errors.As(err, &runtimeErr):
+			got = "runtime"
+		}
+		if got != test.want {
+			t.Errorf("%q: got %v, want a %s error", test.src, err, test.want)
+		}
+
+		var positioned lexer.Error
+		if !errors.As(err, &positioned) {
+			t.Errorf("%q: %v does not report a position", test.src, err)
+		} else if test.pos != (lexer.Position{}) && positioned.Position() != test.pos {
+			t.Errorf("%q: got position %v, want %v", test.src, positioned.Position(), test.pos)
+		}
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	tests := []struct {
+		src  string
+		want error
+	}{
+		{src: "1 / 0", want: ErrDivisionByZero},
+		{src: "5 % 0", want: ErrDivisionByZero},
+		{src: "1.5 / 0", want: ErrDivisionByZero},
+		{src: "0 ^ -1", want: ErrDivisionByZero},
+		{src: "0.0 ^ -0.5", want: ErrDivisionByZero},
+		{src: "missing", want: ErrUndefinedVariable},
+		{src: "assert(1 > 2)", want: ErrAssertionFailed},
+	}
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.Out = io.Discard
+		if _, err := interp.EvalString(test.src); !errors.Is(err, test.want) {
+			t.Errorf("%q: got %v, want %v", test.src, err, test.want)
+		}
+	}
+}
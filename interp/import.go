@@ -0,0 +1,55 @@
+package interp
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"turtle/ast"
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+// importFile runs the file named by an import statement, resolved against the
+// directory of the file being run. The file runs in the global scope, so the
+// functions and variables it defines are visible to every file, and it only
+// runs the first time it is imported; importing a file that is still running
+// is an import cycle.
+func (interp *Interpreter) importFile(stmt *ast.ImportStmt) error {
+	path := stmt.Path.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(interp.File), path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return &RuntimeError{Pos: stmt.Pos(), Msg: fmt.Sprintf("cannot import %s: %v", stmt.Path, err)}
+	}
+	if done, seen := interp.imports[path]; seen {
+		if !done {
+			return &RuntimeError{Pos: stmt.Pos(), Msg: fmt.Sprintf("import cycle: %s is already being imported", stmt.Path)}
+		}
+		return nil
+	}
+
+	lex, err := lexer.NewLexerFromFile(path)
+	if err != nil {
+		return &RuntimeError{Pos: stmt.Pos(), Msg: fmt.Sprintf("cannot import %s: %v", stmt.Path, err)}
+	}
+
+	// The imported file stops at its first error, which fails the import
+	file, env, onError := interp.File, interp.env, interp.OnError
+	interp.File, interp.env, interp.OnError = path, interp.globals, nil
+	err = interp.Run(interp.runContext(), parser.NewParser(lex))
+	interp.File, interp.env, interp.OnError = file, env, onError
+	if err != nil {
+		return &RuntimeError{Pos: stmt.Pos(), Msg: fmt.Sprintf("in %s: %v", stmt.Path, err), Err: err}
+	}
+	return nil
+}
+
+// markImport records whether the file at path has finished running.
+func (interp *Interpreter) markImport(path string, done bool) {
+	if interp.imports == nil {
+		interp.imports = make(map[string]bool)
+	}
+	interp.imports[path] = done
+}
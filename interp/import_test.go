@@ -0,0 +1,110 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+// writeFiles writes files, mapping slash-separated paths to their contents,
+// into a new temporary directory and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runFile runs src as if it were the file main.tl in dir, carrying on after
+// errors, and returns what it printed with each error on a line of its own.
+func runFile(dir, src string, vm bool) string {
+	var out bytes.Buffer
+	interp := NewInterpreter()
+	interp.VM = vm
+	interp.Out = &out
+	interp.File = filepath.Join(dir, "main.tl")
+	interp.OnError = func(err error) {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	if err := interp.Run(context.Background(), parser.NewParser(lexer.NewLexerFromString(src))); err != nil {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	return out.String()
+}
+
+func TestImportRunsFileOnce(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.tl": "print(\"loaded\")\nx = 1\n",
+	})
+	for _, backend := range backends {
+		got := runFile(dir, "import \"lib.tl\"\nimport \"lib.tl\"\nx", backend.vm)
+		if want := "loaded\n1\n"; got != want {
+			t.Errorf("%s: printed %q, want %q", backend.name, got, want)
+		}
+	}
+}
+
+func TestImportResolvesRelativeToImportingFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib/b.tl":     "import \"c.tl\"\n",
+		"lib/c.tl":     "import \"sub/d.tl\"\ny = 2\n",
+		"lib/sub/d.tl": "z = 3\n",
+	})
+	for _, backend := range backends {
+		got := runFile(dir, "import \"lib/b.tl\"\ny + z", backend.vm)
+		if want := "5\n"; got != want {
+			t.Errorf("%s: printed %q, want %q", backend.name, got, want)
+		}
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.tl": "import \"b.tl\"\n",
+		"b.tl": "import \"a.tl\"\n",
+	})
+	for _, backend := range backends {
+		got := runFile(dir, "import \"a.tl\"", backend.vm)
+		if !strings.Contains(got, "import cycle") {
+			t.Errorf("%s: printed %q, want an import cycle error", backend.name, got)
+		}
+	}
+}
+
+func TestFailedImportIsNotMarkedDone(t *testing.T) {
+	// a imports b, which imports c, which imports b again
+	dir := writeFiles(t, map[string]string{
+		"lib/b.tl": "import \"c.tl\"\nb = 1\n",
+		"lib/c.tl": "import \"b.tl\"\n",
+	})
+	for _, backend := range backends {
+		got := runFile(dir, "import \"lib/b.tl\"\nimport \"lib/b.tl\"\nb", backend.vm)
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("%s: printed %q, want three errors", backend.name, got)
+		}
+		for _, line := range lines[:2] {
+			if !strings.Contains(line, "import cycle") {
+				t.Errorf("%s: got %q, want an import cycle error", backend.name, line)
+			}
+		}
+		if !strings.Contains(lines[2], "undefined variable") {
+			t.Errorf("%s: got %q, want b to be undefined", backend.name, lines[2])
+		}
+	}
+}
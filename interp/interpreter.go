@@ -0,0 +1,808 @@
+// Package interp evaluates the syntax tree of a Turtle program.
+package interp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"turtle/ast"
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+// Interpreter evaluates the statements produced by a Parser, keeping
+// variables alive across statements and across parsers.
+type Interpreter struct {
+	env     *Environment // Innermost scope of the statement being executed
+	globals *Environment // Outermost scope, holding the builtins
+	last    Value        // Value of the last expression statement
+
+	// Out receives everything the program prints. NewInterpreter sets it to
+	// os.Stdout.
+	Out io.Writer
+
+	// BufferOutput makes Run collect output in memory and write it to Out
+	// when Run returns, rather than writing every line as it is printed.
+	BufferOutput bool
+
+	// Lenient makes reading an undefined variable yield 0 instead of an
+	// "undefined variable" error.
+	Lenient bool
+
+	// Turtle is moved by the drawing builtins such as forward and left.
+	// NewInterpreter sets it to a new turtle at the origin.
+	Turtle *Turtle
+
+	// Sleep pauses execution for a sleep statement, returning early with
+	// ctx.Err() if the Run's context is cancelled first. NewInterpreter sets
+	// it to pause on a timer.
+	Sleep func(ctx context.Context, d time.Duration) error
+
+	// MaxSteps bounds the number of statements, loop iterations and function
+	// calls each Run evaluates, counting those inside blocks and function
+	// bodies, before it gives up with a "step limit exceeded" error. Zero
+	// means no limit.
+	MaxSteps int
+
+	// VM makes Run compile each statement to bytecode and execute it on a
+	// stack machine instead of walking the syntax tree. Both give the same
+	// results; the tree walker is the reference.
+	VM bool
+
+	// OnError, when set, is called with the error of each failing statement
+	// and Run carries on with the next statement instead of returning the
+	// error.
+	OnError func(error)
+
+	// File is the name of the file Run is reading, against whose directory
+	// import paths are resolved. If it is empty they are resolved against
+	// the working directory.
+	File string
+
+	ctx   context.Context // Context of the Run in progress, if any
+	steps int             // Number of statements, loop iterations and calls evaluated so far
+	calls int             // Number of function calls in progress
+
+	// Files imported so far, by absolute path, mapped to whether they have
+	// finished running
+	imports map[string]bool
+
+	// Bytecode of the function bodies compiled so far for the VM
+	bodies map[*ast.FuncStmt]*chunk
+}
+
+// maxCallDepth bounds how deeply function calls may nest, so runaway
+// recursion fails with an error instead of exhausting the Go stack.
+const maxCallDepth = 10000
+
+// returnSignal carries the value of a return statement out of the function
+// body to the call that is being returned from.
+type returnSignal struct {
+	value Value
+}
+
+func (*returnSignal) Error() string { return "return outside function" }
+
+// NewInterpreter creates a new interpreter with only the builtin functions
+// defined.
+func NewInterpreter() *Interpreter {
+	globals := NewEnvironment(nil)
+	for _, builtin := range builtins {
+		globals.Define(builtin.Name, builtin, "")
+	}
+	return &Interpreter{
+		env:     globals,
+		globals: globals,
+		Out:     os.Stdout,
+		Turtle:  NewTurtle(),
+		Sleep:   sleepContext,
+	}
+}
+
+// Define sets a global variable, so a host program can hand values to the
+// Turtle programs it runs.
+func (interp *Interpreter) Define(name string, value Value) {
+	interp.globals.Define(name, value, "")
+}
+
+// RegisterBuiltin defines a global function implemented in Go. An error it
+// returns is reported as a runtime error at the call.
+func (interp *Interpreter) RegisterBuiltin(name string, fn func(args []Value) (Value, error)) {
+	interp.Define(name, &Builtin{Name: name, Fn: func(_ *Interpreter, pos lexer.Position, args []Value) (Value, error) {
+		value, err := fn(args)
+		if err != nil {
+			if _, ok := err.(lexer.Error); ok {
+				return nil, err
+			}
+			return nil, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("%s: %v", name, err), Err: err}
+		}
+		if value == nil {
+			value = Nil{}
+		}
+		return value, nil
+	}})
+}
+
+// EvalString runs src as a program and returns the value of its last
+// expression statement, or nil if it has none.
+func (interp *Interpreter) EvalString(src string) (Value, error) {
+	return interp.EvalReader(strings.NewReader(src))
+}
+
+// EvalReader runs the program read from r, like EvalString.
+func (interp *Interpreter) EvalReader(r io.Reader) (Value, error) {
+	lex := lexer.NewLexer(r)
+	if err := lex.Err(); err != nil {
+		return nil, err
+	}
+	interp.last = Nil{}
+	if err := interp.Run(context.Background(), parser.NewParser(lex)); err != nil {
+		return nil, err
+	}
+	return interp.last, nil
+}
+
+// Explain returns the current value of a variable together with the source
+// of the expression it was last assigned from.
+func (interp *Interpreter) Explain(name string) (value Value, source string, ok bool) {
+	value, ok = interp.env.Get(name)
+	return value, interp.env.Source(name), ok
+}
+
+// Run evaluates the statements from parser until its input is exhausted,
+// stopping at the first error, once MaxSteps steps have been taken, or when
+// ctx is cancelled, in which case ctx.Err() is returned. Cancellation is
+// noticed between statements, loop iterations and function calls, and during
+// a sleep.
+func (interp *Interpreter) Run(ctx context.Context, parser *parser.Parser) (err error) {
+	outerCtx := interp.ctx
+	interp.ctx = ctx
+	defer func() { interp.ctx = outerCtx }()
+
+	// Every Run gets MaxSteps of its own, except that an imported file
+	// counts against the Run that imports it
+	if outerCtx == nil {
+		interp.steps = 0
+	}
+
+	if interp.BufferOutput {
+		out := interp.Out
+		buffered := bufio.NewWriter(out)
+		interp.Out = buffered
+		defer func() {
+			interp.Out = out
+			if flushErr := buffered.Flush(); err == nil {
+				err = flushErr
+			}
+		}()
+	}
+
+	// While the file runs, importing it again would be a cycle; afterwards it
+	// counts as imported if it ran without error, and is run again by the
+	// next import otherwise
+	if interp.File != "" {
+		if path, absErr := filepath.Abs(interp.File); absErr == nil {
+			if _, seen := interp.imports[path]; !seen {
+				interp.markImport(path, false)
+				defer func() {
+					if err == nil {
+						interp.markImport(path, true)
+					} else {
+						delete(interp.imports, path)
+					}
+				}()
+			}
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		stmt, err := parser.ParseStatement()
+		if err == nil && stmt == nil {
+			return nil
+		}
+		if err == nil {
+			if err = interp.step(stmt.Pos()); err == nil && interp.VM {
+				err = interp.execCompiled(stmt)
+			} else if err == nil {
+				err = interp.Exec(stmt)
+			}
+		}
+
+		if err != nil {
+			// Cancellation and the step limit end the run rather than
+			// failing one statement
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if errors.Is(err, ErrStepLimitExceeded) {
+				return err
+			}
+			if interp.OnError == nil {
+				return err
+			}
+			interp.OnError(err)
+		}
+	}
+}
+
+// runContext returns the context of the Run in progress, or
+// context.Background() if statements are being executed outside of Run.
+func (interp *Interpreter) runContext() context.Context {
+	if interp.ctx == nil {
+		return context.Background()
+	}
+	return interp.ctx
+}
+
+// step counts one statement, loop iteration or call against MaxSteps, and fails
+// with the context's error once the Run in progress is cancelled.
+func (interp *Interpreter) step(pos lexer.Position) error {
+	if err := interp.runContext().Err(); err != nil {
+		return err
+	}
+	if interp.MaxSteps > 0 && interp.steps >= interp.MaxSteps {
+		return &RuntimeError{Pos: pos, Msg: "step limit exceeded", Err: ErrStepLimitExceeded}
+	}
+	interp.steps++
+	return nil
+}
+
+// sleep pauses for the number of milliseconds in value, reporting a type
+// mismatch at pos if it is not a number.
+func (interp *Interpreter) sleep(pos lexer.Position, value Value) error {
+	millis, err := expectNumber(pos, value)
+	if err != nil {
+		return err
+	}
+	return interp.Sleep(interp.runContext(), time.Duration(millis*float64(time.Millisecond)))
+}
+
+// sleepContext pauses for d, or until ctx is cancelled, in which case it
+// returns ctx.Err().
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Exec executes a single statement.
+func (interp *Interpreter) Exec(stmt ast.Stmt) error {
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		value, err := interp.Eval(stmt.Value)
+		if err != nil {
+			return err
+		}
+		interp.env.Assign(stmt.Name.Value, value, stmt.Value.String())
+	case *ast.IfStmt:
+		condition, err := interp.Eval(stmt.Cond)
+		if err != nil {
+			return err
+		}
+
+		// Any non-zero number counts as true, as well as true itself
+		if isTruthy(condition) {
+			return interp.Exec(stmt.Then)
+		}
+		if stmt.Else != nil {
+			return interp.Exec(stmt.Else)
+		}
+	case *ast.SleepStmt:
+		value, err := interp.Eval(stmt.Duration)
+		if err != nil {
+			return err
+		}
+		return interp.sleep(stmt.Duration.Pos(), value)
+	case *ast.ImportStmt:
+		return interp.importFile(stmt)
+	case *ast.ExprStmt:
+		value, err := interp.Eval(stmt.Expr)
+		if err != nil {
+			return err
+		}
+		interp.last = value
+
+		// A call that returns nothing prints nothing
+		if _, isNil := value.(Nil); !isNil {
+			fmt.Fprintln(interp.Out, value.String())
+		}
+	case *ast.IndexAssignStmt:
+		// The list, index and value are all evaluated before the element is
+		// checked, in the same order as on the VM
+		target, err := interp.Eval(stmt.Target.Target)
+		if err != nil {
+			return err
+		}
+		index, err := interp.Eval(stmt.Target.Index)
+		if err != nil {
+			return err
+		}
+		value, err := interp.Eval(stmt.Value)
+		if err != nil {
+			return err
+		}
+		list, i, err := indexList(stmt.Target, target, index)
+		if err != nil {
+			return err
+		}
+		list.Elems[i] = value
+	case *ast.FuncStmt:
+		interp.env.Define(stmt.Name.Value, &Function{Decl: stmt, Closure: interp.env}, "")
+	case *ast.ReturnStmt:
+		var value Value = Nil{}
+		if stmt.Value != nil {
+			var err error
+			if value, err = interp.Eval(stmt.Value); err != nil {
+				return err
+			}
+		}
+		return &returnSignal{value: value}
+	case *ast.WhileStmt:
+		for {
+			condition, err := interp.Eval(stmt.Cond)
+			if err != nil {
+				return err
+			}
+			if !isTruthy(condition) {
+				return nil
+			}
+			if err := interp.step(stmt.Pos()); err != nil {
+				return err
+			}
+			if err := interp.Exec(stmt.Body); err != nil {
+				return err
+			}
+		}
+	case *ast.BlockStmt:
+		return interp.execBlock(stmt)
+	default:
+		return &RuntimeError{Pos: stmt.Pos(), Msg: fmt.Sprintf("cannot execute %T", stmt)}
+	}
+	return nil
+}
+
+// Eval evaluates an expression to its value.
+func (interp *Interpreter) Eval(expr ast.Expr) (Value, error) {
+	switch expr := expr.(type) {
+	case *ast.IntLit:
+		return Int(expr.Value), nil
+	case *ast.FloatLit:
+		return Float(expr.Value), nil
+	case *ast.BoolLit:
+		return Bool(expr.Value), nil
+	case *ast.StringLit:
+		return String(expr.Value), nil
+	case *ast.Ident:
+		return interp.lookup(expr.Token)
+	case *ast.ParenExpr:
+		return interp.Eval(expr.Inner)
+	case *ast.UnaryExpr:
+		operand, err := interp.Eval(expr.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return applyUnary(expr.Op, operand)
+	case *ast.BinaryExpr:
+		left, err := interp.Eval(expr.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		// The logical operators only evaluate their right operand if the
+		// left one does not already decide the result
+		switch expr.Op.Type {
+		case "AND":
+			if !isTruthy(left) {
+				return Bool(false), nil
+			}
+		case "OR":
+			if isTruthy(left) {
+				return Bool(true), nil
+			}
+		}
+
+		right, err := interp.Eval(expr.Right)
+		if err != nil {
+			return nil, err
+		}
+		return interp.applyOperator(expr.Op, left, right)
+	case *ast.ListLit:
+		list := &List{Elems: make([]Value, len(expr.Elems))}
+		for i, elem := range expr.Elems {
+			value, err := interp.Eval(elem)
+			if err != nil {
+				return nil, err
+			}
+			list.Elems[i] = value
+		}
+		return list, nil
+	case *ast.IndexExpr:
+		list, index, err := interp.evalIndex(expr)
+		if err != nil {
+			return nil, err
+		}
+		return list.Elems[index], nil
+	case *ast.CallExpr:
+		return interp.evalCall(expr)
+	case *ast.LetExpr:
+		return interp.evalLet(expr)
+	default:
+		return nil, &RuntimeError{Pos: expr.Pos(), Msg: fmt.Sprintf("cannot evaluate %T", expr)}
+	}
+}
+
+// applyUnary applies a sign or logical not to a value.
+func applyUnary(operator lexer.Token, operand Value) (Value, error) {
+	if operator.Type == "NOT" {
+		return Bool(!isTruthy(operand)), nil
+	}
+	if _, err := expectNumber(operator.Pos, operand); err != nil {
+		return nil, err
+	}
+	if operator.Type == "MINUS" {
+		if i, ok := operand.(Int); ok {
+			if i == math.MinInt64 {
+				return nil, &RuntimeError{Pos: operator.Pos, Msg: fmt.Sprintf("integer overflow: -(%d)", i)}
+			}
+			return -i, nil
+		}
+		return -operand.(Float), nil
+	}
+	return operand, nil
+}
+
+// applyOperator performs a binary operation. Comparisons and logical
+// operators yield a Bool; every other operator needs two numbers, and the
+// result is an Int only if both of them are.
+func (interp *Interpreter) applyOperator(operator lexer.Token, leftValue, rightValue Value) (Value, error) {
+	switch operator.Type {
+	case "EQ":
+		return Bool(valuesEqual(leftValue, rightValue)), nil
+	case "NEQ":
+		return Bool(!valuesEqual(leftValue, rightValue)), nil
+	case "AND", "OR":
+		// The left operand did not decide the result, so the right one does
+		return Bool(isTruthy(rightValue)), nil
+	}
+
+	// Adding two strings joins them
+	if leftString, ok := leftValue.(String); ok && operator.Type == "PLUS" {
+		if rightString, ok := rightValue.(String); ok {
+			return leftString + rightString, nil
+		}
+	}
+
+	left, leftNumeric := toFloat(leftValue)
+	right, rightNumeric := toFloat(rightValue)
+	if !leftNumeric || !rightNumeric {
+		return nil, &RuntimeError{Pos: operator.Pos, Msg: fmt.Sprintf("type mismatch: %s %s %s", leftValue.Kind(), operator.Value, rightValue.Kind())}
+	}
+	if leftInt, ok := leftValue.(Int); ok {
+		if rightInt, ok := rightValue.(Int); ok {
+			return applyIntOperator(operator, leftInt, rightInt)
+		}
+	}
+	return applyFloatOperator(operator, Float(left), Float(right))
+}
+
+// applyIntOperator performs an arithmetic or ordering operation on two Ints.
+// Division and negative powers yield a Float unless the result is whole, and
+// a result too large for an Int is an error.
+func applyIntOperator(operator lexer.Token, left, right Int) (Value, error) {
+	overflow := func() error {
+		return &RuntimeError{Pos: operator.Pos, Msg: fmt.Sprintf("integer overflow: %d %s %d", left, operator.Value, right)}
+	}
+
+	switch operator.Type {
+	case "DIVIDE":
+		if right == 0 {
+			return nil, &RuntimeError{Pos: operator.Pos, Msg: "division by zero", Err: ErrDivisionByZero}
+		}
+		if left == math.MinInt64 && right == -1 {
+			return nil, overflow()
+		}
+		if left%right != 0 {
+			return Float(left) / Float(right), nil
+		}
+		return left / right, nil
+	case "MODULO":
+		if right == 0 {
+			return nil, &RuntimeError{Pos: operator.Pos, Msg: "modulo by zero", Err: ErrDivisionByZero}
+		}
+		return left % right, nil
+	case "POWER":
+		if right < 0 {
+			// A negative power of zero divides one by zero
+			if left == 0 {
+				return nil, &RuntimeError{Pos: operator.Pos, Msg: "division by zero", Err: ErrDivisionByZero}
+			}
+			return Float(math.Pow(float64(left), float64(right))), nil
+		}
+		result, ok := intPower(left, right)
+		if !ok {
+			return nil, overflow()
+		}
+		return result, nil
+	case "PLUS":
+		sum := left + right
+		if (sum > left) != (right > 0) {
+			return nil, overflow()
+		}
+		return sum, nil
+	case "MINUS":
+		difference := left - right
+		if (difference < left) != (right > 0) {
+			return nil, overflow()
+		}
+		return difference, nil
+	case "MULTIPLY":
+		product, ok := multiplyInts(left, right)
+		if !ok {
+			return nil, overflow()
+		}
+		return product, nil
+	case "LT":
+		return Bool(left < right), nil
+	case "GT":
+		return Bool(left > right), nil
+	case "LTE":
+		return Bool(left <= right), nil
+	case "GTE":
+		return Bool(left >= right), nil
+	default:
+		return nil, &RuntimeError{Pos: operator.Pos, Msg: fmt.Sprintf("unknown operator %q", operator.Value)}
+	}
+}
+
+// intPower raises base to a non-negative exponent by repeated squaring. It
+// reports false if the result does not fit in an Int.
+func intPower(base, exponent Int) (Int, bool) {
+	result, ok := Int(1), true
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = multiplyInts(result, base); !ok {
+				return 0, false
+			}
+		}
+		exponent >>= 1
+
+		// The square is only needed, and only has to fit, if more bits follow
+		if exponent > 0 {
+			if base, ok = multiplyInts(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// multiplyInts multiplies two Ints, reporting false if the product does not
+// fit in an Int.
+func multiplyInts(a, b Int) (Int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+// applyFloatOperator performs an arithmetic or ordering operation on two
+// Floats.
+func applyFloatOperator(operator lexer.Token, left, right Float) (Value, error) {
+	switch operator.Type {
+	case "PLUS":
+		return left + right, nil
+	case "MINUS":
+		return left - right, nil
+	case "MULTIPLY":
+		return left * right, nil
+	case "DIVIDE":
+		if right == 0 {
+			return nil, &RuntimeError{Pos: operator.Pos, Msg: "division by zero", Err: ErrDivisionByZero}
+		}
+		return left / right, nil
+	case "MODULO":
+		if right == 0 {
+			return nil, &RuntimeError{Pos: operator.Pos, Msg: "modulo by zero", Err: ErrDivisionByZero}
+		}
+		return Float(math.Mod(float64(left), float64(right))), nil
+	case "POWER":
+		if left == 0 && right < 0 {
+			return nil, &RuntimeError{Pos: operator.Pos, Msg: "division by zero", Err: ErrDivisionByZero}
+		}
+		return Float(math.Pow(float64(left), float64(right))), nil
+	case "LT":
+		return Bool(left < right), nil
+	case "GT":
+		return Bool(left > right), nil
+	case "LTE":
+		return Bool(left <= right), nil
+	case "GTE":
+		return Bool(left >= right), nil
+	default:
+		return nil, &RuntimeError{Pos: operator.Pos, Msg: fmt.Sprintf("unknown operator %q", operator.Value)}
+	}
+}
+
+// evalLet evaluates a let expression, which binds its name only while the
+// body is evaluated. Any outer variable of the same name is shadowed rather
+// than overwritten.
+func (interp *Interpreter) evalLet(let *ast.LetExpr) (Value, error) {
+	value, err := interp.Eval(let.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bind the name in a scope of its own for the body only
+	outer := interp.env
+	interp.env = NewEnvironment(outer)
+	interp.env.Define(let.Name.Value, value, let.Value.String())
+	defer func() { interp.env = outer }()
+
+	return interp.Eval(let.Body)
+}
+
+// evalIndex evaluates the list and index of an index expression, checking
+// that the index is a whole number within the list once both have been
+// evaluated.
+func (interp *Interpreter) evalIndex(expr *ast.IndexExpr) (*List, int, error) {
+	target, err := interp.Eval(expr.Target)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, err := interp.Eval(expr.Index)
+	if err != nil {
+		return nil, 0, err
+	}
+	return indexList(expr, target, value)
+}
+
+// indexList checks that target is a list and value a whole number within it,
+// reporting any problem at the matching part of expr.
+func indexList(expr *ast.IndexExpr, target, value Value) (*List, int, error) {
+	list, ok := target.(*List)
+	if !ok {
+		return nil, 0, &RuntimeError{Pos: expr.Lbrack.Pos, Msg: fmt.Sprintf("cannot index %s", target.Kind())}
+	}
+	index, ok := value.(Int)
+	if !ok {
+		return nil, 0, &RuntimeError{Pos: expr.Index.Pos(), Msg: fmt.Sprintf("type mismatch: expected int, got %s", value.Kind())}
+	}
+	if index < 0 || int(index) >= len(list.Elems) {
+		return nil, 0, &RuntimeError{Pos: expr.Index.Pos(), Msg: fmt.Sprintf("index %d out of range for list of length %d", index, len(list.Elems))}
+	}
+	return list, int(index), nil
+}
+
+// evalCall calls a function. The arguments are bound to the parameters in a
+// new scope inside the one the function was defined in, so each call has
+// variables of its own.
+func (interp *Interpreter) evalCall(call *ast.CallExpr) (Value, error) {
+	callee, err := interp.Eval(call.Callee)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate the arguments in the caller's scope
+	args := make([]Value, len(call.Args))
+	for i, arg := range call.Args {
+		if args[i], err = interp.Eval(arg); err != nil {
+			return nil, err
+		}
+	}
+	return interp.call(call, callee, args)
+}
+
+// call calls callee with the evaluated arguments of call. A function body
+// runs on the VM if it is enabled and is walked otherwise.
+func (interp *Interpreter) call(call *ast.CallExpr, callee Value, args []Value) (Value, error) {
+	if err := interp.step(call.Lparen.Pos); err != nil {
+		return nil, err
+	}
+
+	var fn *Function
+	switch callee := callee.(type) {
+	case *Builtin:
+		return callee.Fn(interp, call.Lparen.Pos, args)
+	case *Function:
+		fn = callee
+	default:
+		return nil, &RuntimeError{Pos: call.Lparen.Pos, Msg: fmt.Sprintf("cannot call %s", callee.Kind())}
+	}
+	if len(call.Args) != len(fn.Decl.Params) {
+		return nil, &RuntimeError{Pos: call.Lparen.Pos, Msg: fmt.Sprintf("%s expects %d arguments, got %d", fn.Decl.Name.Value, len(fn.Decl.Params), len(call.Args))}
+	}
+	if interp.calls >= maxCallDepth {
+		return nil, &RuntimeError{Pos: call.Lparen.Pos, Msg: "maximum call depth exceeded"}
+	}
+
+	locals := NewEnvironment(fn.Closure)
+	for i, param := range fn.Decl.Params {
+		locals.Define(param.Value, args[i], call.Args[i].String())
+	}
+
+	outer := interp.env
+	interp.env = locals
+	interp.calls++
+	defer func() {
+		interp.env = outer
+		interp.calls--
+	}()
+
+	var result Value = Nil{}
+	var err error
+	if interp.VM {
+		var body *chunk
+		if body, err = interp.compiledBody(fn.Decl); err == nil {
+			result, err = interp.runChunk(body)
+		}
+	} else if err = interp.execBlock(fn.Decl.Body); err != nil {
+		if ret, ok := err.(*returnSignal); ok {
+			result, err = ret.value, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// execBlock executes the statements of a block in a new scope, so variables
+// first assigned inside the block are gone once it ends.
+func (interp *Interpreter) execBlock(block *ast.BlockStmt) error {
+	outer := interp.env
+	interp.env = NewEnvironment(outer)
+	defer func() { interp.env = outer }()
+
+	for _, stmt := range block.Stmts {
+		if err := interp.step(stmt.Pos()); err != nil {
+			return err
+		}
+		if err := interp.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the value of the variable named by the token.
+func (interp *Interpreter) lookup(varToken lexer.Token) (Value, error) {
+	value, ok := interp.env.Get(varToken.Value)
+	if !ok {
+		if !interp.Lenient {
+			return nil, &RuntimeError{Pos: varToken.Pos, Msg: fmt.Sprintf("undefined variable '%s'", varToken.Value), Err: ErrUndefinedVariable}
+		}
+		return Int(0), nil
+	}
+	return value, nil
+}
+
+// expectNumber returns value as a number, or a type mismatch error at pos if
+// it is of another kind.
+func expectNumber(pos lexer.Position, value Value) (float64, error) {
+	number, ok := toFloat(value)
+	if !ok {
+		return 0, &RuntimeError{Pos: pos, Msg: fmt.Sprintf("type mismatch: expected number, got %s", value.Kind())}
+	}
+	return number, nil
+}
@@ -0,0 +1,586 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+// backends names the two ways an interpreter can run a program, for tests
+// that check both.
+var backends = []struct {
+	name string
+	vm   bool
+}{
+	{name: "tree", vm: false},
+	{name: "vm", vm: true},
+}
+
+func TestRunCancelsInsideLoop(t *testing.T) {
+	programs := []string{
+		"while true { x = 1 }",
+		"fn spin() { while true { x = 1 } }\nspin()",
+		"fn f(n) {\n  if n == 0 { return 0 }\n  return f(n - 1) + f(n - 1)\n}\nf(60)",
+	}
+	for _, backend := range backends {
+		for _, src := range programs {
+			interp := NewInterpreter()
+			interp.VM = backend.vm
+			interp.Out = io.Discard
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			done := make(chan error, 1)
+			go func() {
+				done <- interp.Run(ctx, parser.NewParser(lexer.NewLexerFromString(src)))
+			}()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("%s: %q: got %v, want %v", backend.name, src, err, context.DeadlineExceeded)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: %q: still running 2s after a 50ms timeout", backend.name, src)
+			}
+			cancel()
+		}
+	}
+}
+
+func TestRunCancelledReportsNoStatementError(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	interp.OnError = func(err error) {
+		t.Errorf("OnError called with %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := interp.Run(ctx, parser.NewParser(lexer.NewLexerFromString("while true { x = 1 }")))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRunCancelsInsideImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.t")
+	if err := os.WriteFile(path, []byte("while true { x = 1 }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := interp.Run(ctx, parser.NewParser(lexer.NewLexerFromString("import "+strconv.Quote(path))))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMaxStepsStopsInfiniteLoop(t *testing.T) {
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		interp.MaxSteps = 1000
+
+		_, err := interp.EvalString("i = 0\nwhile true { i = i + 1 }")
+		if !errors.Is(err, ErrStepLimitExceeded) {
+			t.Fatalf("%s: got %v, want %v", backend.name, err, ErrStepLimitExceeded)
+		}
+
+		// Both top-level statements fit in the limit, and then 499 iterations
+		// of one step for the loop and one for the statement in its body
+		if value, ok := interp.globals.Get("i"); !ok || value != Int(499) {
+			t.Errorf("%s: i = %v after the limit, want 499", backend.name, value)
+		}
+	}
+}
+
+func TestMaxStepsStopsRecursion(t *testing.T) {
+	src := "fn f(n) {\n  if n == 0 { return 0 }\n  return f(n - 1) + f(n - 1)\n}\nf(40)"
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		interp.MaxSteps = 1000
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := interp.EvalString(src)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrStepLimitExceeded) {
+				t.Errorf("%s: got %v, want %v", backend.name, err, ErrStepLimitExceeded)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: still running 2s into a 1000 step limit", backend.name)
+		}
+	}
+}
+
+func TestMaxStepsEndsRunDespiteOnError(t *testing.T) {
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		interp.MaxSteps = 3
+		interp.OnError = func(err error) {
+			t.Errorf("%s: OnError called with %v", backend.name, err)
+		}
+
+		src := "a = 1\nb = 2\nc = 3\nd = 4\ne = 5\nf = 6"
+		err := interp.Run(context.Background(), parser.NewParser(lexer.NewLexerFromString(src)))
+		if !errors.Is(err, ErrStepLimitExceeded) {
+			t.Errorf("%s: got %v, want %v", backend.name, err, ErrStepLimitExceeded)
+		}
+	}
+}
+
+func TestMaxStepsAppliesToEachRun(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	interp.MaxSteps = 5
+	for i := 0; i < 10; i++ {
+		if _, err := interp.EvalString("a = 1\nb = 2"); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestIntPower(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "2 ^ 10", want: Int(1024)},
+		{src: "2 ^ 0", want: Int(1)},
+		{src: "0 ^ 0", want: Int(1)},
+		{src: "0 ^ 30000000000", want: Int(0)},
+		{src: "1 ^ 30000000000", want: Int(1)},
+		{src: "(-1) ^ 30000000001", want: Int(-1)},
+		{src: "2 ^ 62", want: Int(1 << 62)},
+		{src: "(-2) ^ 63", want: Int(math.MinInt64)},
+		{src: "3 ^ 39", want: Int(4052555153018976267)},
+		{src: "2 ^ -1", want: Float(0.5)},
+	}
+	for _, backend := range backends {
+		for _, test := range tests {
+			interp := NewInterpreter()
+			interp.VM = backend.vm
+			interp.Out = io.Discard
+			got, err := interp.EvalString(test.src)
+			if err != nil || got != test.want {
+				t.Errorf("%s: %s = %v (%v), want %v", backend.name, test.src, got, err, test.want)
+			}
+		}
+	}
+}
+
+func TestIntOverflow(t *testing.T) {
+	programs := []string{
+		"2 ^ 63", "(-2) ^ 64", "3 ^ 40", "10 ^ 30000000000",
+		"2 ^ 62 * 4", "(-2) ^ 62 * -2 * -1",
+		"9223372036854775807 + 1", "-9223372036854775807 + -2",
+		"-9223372036854775807 - 2", "9223372036854775807 - -1",
+		"(-9223372036854775807 - 1) / -1", "-(-9223372036854775807 - 1)",
+	}
+	for _, backend := range backends {
+		for _, src := range programs {
+			interp := NewInterpreter()
+			interp.VM = backend.vm
+			interp.Out = io.Discard
+			_, err := interp.EvalString(src)
+			if err == nil || !strings.Contains(err.Error(), "integer overflow") {
+				t.Errorf("%s: %s: got %v, want an integer overflow error", backend.name, src, err)
+			}
+		}
+	}
+
+	// Results right at the ends of the range still fit
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "9223372036854775806 + 1", want: Int(math.MaxInt64)},
+		{src: "-9223372036854775807 - 1", want: Int(math.MinInt64)},
+		{src: "2 ^ 62 * -2", want: Int(math.MinInt64)},
+		{src: "(-9223372036854775807 - 1) / 1", want: Int(math.MinInt64)},
+		{src: "9223372036854775807 + -9223372036854775807", want: Int(0)},
+	}
+	for _, test := range tests {
+		if got := evalBoth(t, test.src); got != test.want {
+			t.Errorf("%s = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+// runProgram runs src in a new interpreter on the given backend and returns
+// what it printed, stopping at the first error.
+func runProgram(src string, vm bool) (string, error) {
+	var out bytes.Buffer
+	interp := NewInterpreter()
+	interp.VM = vm
+	interp.Out = &out
+	err := interp.Run(context.Background(), parser.NewParser(lexer.NewLexerFromString(src)))
+	return out.String(), err
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // Everything the program prints
+	}{
+		{
+			name: "arithmetic",
+			src:  "1 + 1\n10 - 5\n10 / 2\n2 * 6\n7 / 2\n1.5 * 2",
+			want: "2\n5\n5\n12\n3.5\n3.0\n",
+		},
+		{
+			name: "variables",
+			src:  "a = 5\nb = 10\n5 + a - b + 6\na = a + 1\na",
+			want: "6\n6\n",
+		},
+		{
+			name: "strings",
+			src:  "name = \"turtle\"\nprint(\"hello, \" + name, 3)\n\"tab\\tquote\\\"\"",
+			want: "hello, turtle 3\ntab\tquote\"\n",
+		},
+		{
+			name: "while",
+			src:  "i = 0\nwhile i < 3 {\n  print(i)\n  i = i + 1\n}\ni",
+			want: "0\n1\n2\n3\n",
+		},
+		{
+			name: "block scope",
+			src:  "x = 1\n{\n  x = 2\n  y = 3\n}\nx\nlet y = 4 in y",
+			want: "2\n4\n",
+		},
+		{
+			name: "recursion",
+			src:  "fn fact(n) {\n  if n <= 1 { return 1 }\n  return n * fact(n - 1)\n}\nfact(5)",
+			want: "120\n",
+		},
+		{
+			name: "closures",
+			src:  "fn adder(n) {\n  fn add(x) { return x + n }\n  return add\n}\nadd2 = adder(2)\nadd2(40)",
+			want: "42\n",
+		},
+		{
+			name: "lists",
+			src:  "xs = [1, 2, 3]\nxs[0] = 10\npush(xs, \"four\")\nxs\nlen(xs)\nys = xs\npush(ys, 5)\nlen(xs)",
+			want: "[10, 2, 3, \"four\"]\n4\n5\n",
+		},
+		{
+			name: "list containing itself",
+			src:  "xs = [1]; push(xs, xs); xs\nys = [2]\n[ys, ys]",
+			want: "[1, [...]]\n[[2], [2]]\n",
+		},
+		{
+			name: "logic",
+			src:  "a = 5\nb = 10\na < b && !(a == b)\na > b || false\nif a > b then c = a else c = b\nc",
+			want: "true\nfalse\n10\n",
+		},
+		{
+			name: "statements on one line",
+			src:  "x = 1; y = 2; x + y",
+			want: "3\n",
+		},
+		{
+			name: "nil is not printed",
+			src:  "fn nothing() { return }\nnothing()\nprint(nothing())",
+			want: "nil\n",
+		},
+	}
+	for _, test := range tests {
+		for _, backend := range backends {
+			got, err := runProgram(test.src, backend.vm)
+			if err != nil {
+				t.Errorf("%s (%s): %v", test.name, backend.name, err)
+			} else if got != test.want {
+				t.Errorf("%s (%s): printed %q, want %q", test.name, backend.name, got, test.want)
+			}
+		}
+	}
+}
+
+// evalBoth evaluates src on both backends and returns the value of its last
+// expression statement, failing the test if either fails or they disagree.
+func evalBoth(t *testing.T, src string) Value {
+	t.Helper()
+	var values [2]Value
+	for i, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		value, err := interp.EvalString(src)
+		if err != nil {
+			t.Fatalf("%s: %q: %v", backend.name, src, err)
+		}
+		values[i] = value
+	}
+	if !valuesEqual(values[0], values[1]) || values[0].Kind() != values[1].Kind() {
+		t.Fatalf("%q: tree walker gave %v, vm gave %v", src, values[0], values[1])
+	}
+	return values[0]
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "2 + 3 * 4", want: Int(14)},
+		{src: "2 * 3 + 4", want: Int(10)},
+		{src: "10 - 2 - 3", want: Int(5)},
+		{src: "100 / 10 / 5", want: Int(2)},
+		{src: "(2 + 3) * 4", want: Int(20)},
+		{src: "2 * (3 + 4) - 1", want: Int(13)},
+		{src: "1 + 2 < 2 * 2", want: Bool(true)},
+	}
+	for _, test := range tests {
+		if got := evalBoth(t, test.src); got != test.want {
+			t.Errorf("%s = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestIfElse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "if 1 then x = 5 else x = 9\nx", want: Int(5)},
+		{src: "if 0 then x = 5 else x = 9\nx", want: Int(9)},
+		{src: "x = 1\nif 0 then x = 5\nx", want: Int(1)},
+		{src: "a = 3\nif a >= 3 then b = \"yes\" else b = \"no\"\nb", want: String("yes")},
+		{src: "x = 0\nif 2 != 2 { x = 1 } else { x = 2 }\nx", want: Int(2)},
+		{src: "1 == 1\n", want: Bool(true)},
+		{src: "2 <= 1", want: Bool(false)},
+	}
+	for _, test := range tests {
+		if got := evalBoth(t, test.src); got != test.want {
+			t.Errorf("%q = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestUnaryMinus(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "-5", want: Int(-5)},
+		{src: "3 * -2", want: Int(-6)},
+		{src: "2 - -3", want: Int(5)},
+		{src: "-(2 + 3)", want: Int(-5)},
+		{src: "x = 4\n-x + 1", want: Int(-3)},
+		{src: "-1.5", want: Float(-1.5)},
+		{src: "--2", want: Int(2)},
+	}
+	for _, test := range tests {
+		if got := evalBoth(t, test.src); got != test.want {
+			t.Errorf("%q = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestModuloAndPower(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "10 % 3", want: Int(1)},
+		{src: "-7 % 3", want: Int(-1)},
+		{src: "7.5 % 2", want: Float(1.5)},
+		{src: "2 ^ 10", want: Int(1024)},
+		{src: "2 ^ 3 ^ 2", want: Int(512)},
+		{src: "(2 ^ 3) ^ 2", want: Int(64)},
+		{src: "2 * 3 ^ 2", want: Int(18)},
+		{src: "-2 ^ 2", want: Int(-4)},
+		{src: "10 % 4 * 2", want: Int(4)},
+		{src: "4 ^ 0.5", want: Float(2)},
+	}
+	for _, test := range tests {
+		if got := evalBoth(t, test.src); got != test.want {
+			t.Errorf("%q = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestOutputGoesToOut(t *testing.T) {
+	for _, backend := range backends {
+		var out bytes.Buffer
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = &out
+		if _, err := interp.EvalString("x = 6\nx * 7\nprint(\"x is\", x)"); err != nil {
+			t.Fatal(err)
+		}
+		if want := "42\nx is 6\n"; out.String() != want {
+			t.Errorf("%s: printed %q, want %q", backend.name, out.String(), want)
+		}
+	}
+}
+
+func TestBufferOutput(t *testing.T) {
+	var out bytes.Buffer
+	interp := NewInterpreter()
+	interp.Out = &out
+	interp.BufferOutput = true
+
+	// Record how much had reached Out by the time each line was printed
+	var seen []int
+	interp.RegisterBuiltin("seen", func(args []Value) (Value, error) {
+		seen = append(seen, out.Len())
+		return nil, nil
+	})
+	if _, err := interp.EvalString("1\nseen()\nprint(2)\nseen()"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 2 || seen[0] != 0 || seen[1] != 0 {
+		t.Errorf("Out held %v bytes while running, want nothing", seen)
+	}
+	if out.String() != "1\n2\n" {
+		t.Errorf("printed %q after running, want %q", out.String(), "1\n2\n")
+	}
+	if interp.Out != &out {
+		t.Errorf("Out was not restored after running")
+	}
+}
+
+func TestBufferOutputFlushesOnError(t *testing.T) {
+	var out bytes.Buffer
+	interp := NewInterpreter()
+	interp.Out = &out
+	interp.BufferOutput = true
+	if _, err := interp.EvalString("print(\"before\")\n1 / 0\nprint(\"after\")"); err == nil {
+		t.Fatal("got no error")
+	}
+	if out.String() != "before\n" {
+		t.Errorf("printed %q, want %q", out.String(), "before\n")
+	}
+}
+
+func TestUndefinedVariable(t *testing.T) {
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		if _, err := interp.EvalString("missing + 1"); !errors.Is(err, ErrUndefinedVariable) {
+			t.Errorf("%s: strict: got %v, want %v", backend.name, err, ErrUndefinedVariable)
+		}
+
+		interp.Lenient = true
+		got, err := interp.EvalString("missing + 1")
+		if err != nil || got != Int(1) {
+			t.Errorf("%s: lenient: got %v, %v, want 1", backend.name, got, err)
+		}
+	}
+}
+
+func TestSleepUsesInjectedSleeper(t *testing.T) {
+	for _, backend := range backends {
+		var slept []time.Duration
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		interp.Sleep = func(_ context.Context, d time.Duration) error {
+			slept = append(slept, d)
+			return nil
+		}
+		if _, err := interp.EvalString("sleep 250\nsleep 1.5\ni = 0\nwhile i < 2 {\n  sleep 10\n  i = i + 1\n}"); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []time.Duration{250 * time.Millisecond, 1500 * time.Microsecond, 10 * time.Millisecond, 10 * time.Millisecond}
+		if fmt.Sprint(slept) != fmt.Sprint(want) {
+			t.Errorf("%s: slept %v, want %v", backend.name, slept, want)
+		}
+	}
+}
+
+func TestRunCancelsDuringSleep(t *testing.T) {
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		done := make(chan error, 1)
+		go func() {
+			done <- interp.Run(ctx, parser.NewParser(lexer.NewLexerFromString("sleep 100000")))
+		}()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s: got %v, want %v", backend.name, err, context.DeadlineExceeded)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: still sleeping 2s after a 50ms timeout", backend.name)
+		}
+		cancel()
+	}
+}
+
+func TestLetScope(t *testing.T) {
+	if got := evalBoth(t, "let t = 2 in t * 3"); got != Int(6) {
+		t.Errorf("let t = 2 in t * 3 = %v, want 6", got)
+	}
+
+	// An outer variable of the same name is shadowed, not overwritten
+	if got := evalBoth(t, "t = 10\nlet t = 2 in t * 3\nt"); got != Int(10) {
+		t.Errorf("t = %v after the let, want 10", got)
+	}
+
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		if _, err := interp.EvalString("let t = 2 in t * 3\nt"); !errors.Is(err, ErrUndefinedVariable) {
+			t.Errorf("%s: reading t after the let gave %v, want %v", backend.name, err, ErrUndefinedVariable)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	for _, backend := range backends {
+		interp := NewInterpreter()
+		interp.VM = backend.vm
+		interp.Out = io.Discard
+		if _, err := interp.EvalString("x = 1 + 2\ny = x * 2"); err != nil {
+			t.Fatal(err)
+		}
+
+		value, source, ok := interp.Explain("x")
+		if !ok || value != Int(3) || source != "1 + 2" {
+			t.Errorf("%s: Explain(x) = %v, %q, %v, want 3, \"1 + 2\", true", backend.name, value, source, ok)
+		}
+		value, source, ok = interp.Explain("y")
+		if !ok || value != Int(6) || source != "x * 2" {
+			t.Errorf("%s: Explain(y) = %v, %q, %v, want 6, \"x * 2\", true", backend.name, value, source, ok)
+		}
+		if _, _, ok := interp.Explain("z"); ok {
+			t.Errorf("%s: Explain(z) found an undefined variable", backend.name)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Turtle is the pen the drawing builtins move around. It starts at the
+// origin facing up with its pen down, and records every line it draws so the
+// drawing can be rendered once the program has finished.
+type Turtle struct {
+	X, Y    float64 // Position, with y growing downwards as on screen
+	Heading float64 // Direction in degrees clockwise from straight up
+	PenDown bool    // Whether moving draws a line
+	Color   string  // Color of the lines drawn from now on
+	Lines   []Line  // Every line drawn so far
+}
+
+// Line is one straight stroke of the turtle's pen.
+type Line struct {
+	X1, Y1, X2, Y2 float64
+	Color          string
+}
+
+// NewTurtle creates a turtle at the origin facing up, drawing in black.
+func NewTurtle() *Turtle {
+	return &Turtle{PenDown: true, Color: "black"}
+}
+
+// Forward moves the turtle distance steps in the direction it is facing,
+// drawing a line if the pen is down. A negative distance moves it backwards.
+func (t *Turtle) Forward(distance float64) {
+	radians := t.Heading * math.Pi / 180
+	x := t.X + distance*math.Sin(radians)
+	y := t.Y - distance*math.Cos(radians)
+	if t.PenDown {
+		t.Lines = append(t.Lines, Line{X1: t.X, Y1: t.Y, X2: x, Y2: y, Color: t.Color})
+	}
+	t.X, t.Y = x, y
+}
+
+// Turn turns the turtle degrees clockwise, or anticlockwise if degrees is
+// negative.
+func (t *Turtle) Turn(degrees float64) {
+	t.Heading = math.Mod(t.Heading+degrees, 360)
+}
+
+// drawingMargin is the space left around the drawing when it is rendered.
+const drawingMargin = 10
+
+// maxPNGSize is the largest width and height of a PNG image in pixels. A
+// larger drawing is scaled down to fit.
+const maxPNGSize = 4096
+
+// errDrawingTooLarge is reported when the turtle has moved so far that the
+// size of the drawing cannot be represented.
+var errDrawingTooLarge = errors.New("drawing is too large to render")
+
+// bounds returns the smallest rectangle holding every line and the origin,
+// or errDrawingTooLarge if its size overflows.
+func (t *Turtle) bounds() (minX, minY, maxX, maxY float64, err error) {
+	for _, line := range t.Lines {
+		minX = math.Min(minX, math.Min(line.X1, line.X2))
+		minY = math.Min(minY, math.Min(line.Y1, line.Y2))
+		maxX = math.Max(maxX, math.Max(line.X1, line.X2))
+		maxY = math.Max(maxY, math.Max(line.Y1, line.Y2))
+	}
+	minX, minY, maxX, maxY = minX-drawingMargin, minY-drawingMargin, maxX+drawingMargin, maxY+drawingMargin
+	for _, size := range []float64{maxX - minX, maxY - minY} {
+		if math.IsInf(size, 0) || math.IsNaN(size) {
+			return 0, 0, 0, 0, errDrawingTooLarge
+		}
+	}
+	return minX, minY, maxX, maxY, nil
+}
+
+// WriteSVG renders the drawing as an SVG image.
+func (t *Turtle) WriteSVG(w io.Writer) error {
+	minX, minY, maxX, maxY, err := t.bounds()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"%s %s %s %s\" width=\"%s\" height=\"%s\">\n",
+		svgNumber(minX), svgNumber(minY), svgNumber(maxX-minX), svgNumber(maxY-minY), svgNumber(maxX-minX), svgNumber(maxY-minY))
+	for _, line := range t.Lines {
+		fmt.Fprintf(&sb, "  <line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"%s\" stroke-width=\"2\" stroke-linecap=\"round\"/>\n",
+			svgNumber(line.X1), svgNumber(line.Y1), svgNumber(line.X2), svgNumber(line.Y2), html.EscapeString(line.Color))
+	}
+	sb.WriteString("</svg>\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// svgNumber formats a coordinate to two decimal places, which hides the
+// rounding errors of turning, leaving off trailing zeros. Adding 0 turns -0
+// into 0. Coordinates too large to have any decimals are left as they are.
+func svgNumber(f float64) string {
+	if math.Abs(f) < 1e15 {
+		f = math.Round(f*100) / 100
+	}
+	return strconv.FormatFloat(f+0, 'f', -1, 64)
+}
+
+// WritePNG renders the drawing as a PNG image on a white background, one
+// pixel per step, or scaled down to at most maxPNGSize pixels across if it
+// is larger than that.
+func (t *Turtle) WritePNG(w io.Writer) error {
+	minX, minY, maxX, maxY, err := t.bounds()
+	if err != nil {
+		return err
+	}
+	scale := math.Min(1, maxPNGSize/math.Max(maxX-minX, maxY-minY))
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil((maxX-minX)*scale)), int(math.Ceil((maxY-minY)*scale))))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	for _, line := range t.Lines {
+		// Colors were checked when they were chosen
+		c, _ := parseColor(line.Color)
+
+		// Step along the line one pixel at a time, with a 2x2 pen
+		x1, y1 := (line.X1-minX)*scale, (line.Y1-minY)*scale
+		dx, dy := (line.X2-line.X1)*scale, (line.Y2-line.Y1)*scale
+		steps := math.Max(1, math.Ceil(math.Max(math.Abs(dx), math.Abs(dy))))
+		for i := 0.0; i <= steps; i++ {
+			x := int(math.Round(x1 + dx*i/steps))
+			y := int(math.Round(y1 + dy*i/steps))
+			img.Set(x, y, c)
+			img.Set(x+1, y, c)
+			img.Set(x, y+1, c)
+			img.Set(x+1, y+1, c)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// colorNames are the colors color() accepts by name, besides "#rrggbb".
+var colorNames = map[string]color.RGBA{
+	"black":   {0x00, 0x00, 0x00, 0xff},
+	"white":   {0xff, 0xff, 0xff, 0xff},
+	"gray":    {0x80, 0x80, 0x80, 0xff},
+	"red":     {0xff, 0x00, 0x00, 0xff},
+	"green":   {0x00, 0x80, 0x00, 0xff},
+	"blue":    {0x00, 0x00, 0xff, 0xff},
+	"yellow":  {0xff, 0xff, 0x00, 0xff},
+	"orange":  {0xff, 0xa5, 0x00, 0xff},
+	"purple":  {0x80, 0x00, 0x80, 0xff},
+	"brown":   {0xa5, 0x2a, 0x2a, 0xff},
+	"pink":    {0xff, 0xc0, 0xcb, 0xff},
+	"cyan":    {0x00, 0xff, 0xff, 0xff},
+	"magenta": {0xff, 0x00, 0xff, 0xff},
+}
+
+// parseColor returns the color with the given name or "#rrggbb" code.
+func parseColor(name string) (color.RGBA, bool) {
+	if c, ok := colorNames[strings.ToLower(name)]; ok {
+		return c, true
+	}
+	if len(name) != 7 || name[0] != '#' {
+		return color.RGBA{}, false
+	}
+	rgb, err := strconv.ParseUint(name[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}, true
+}
@@ -0,0 +1,79 @@
+package interp
+
+import (
+	"bytes"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMoveRejectsNonFiniteNumbers(t *testing.T) {
+	for _, src := range []string{"forward(10.0 ^ 400)", "back(-(10.0 ^ 400))", "left(10.0 ^ 400)", "forward(0.0 * 10.0 ^ 400)"} {
+		interp := NewInterpreter()
+		interp.Out = io.Discard
+		_, err := interp.EvalString(src)
+		if err == nil || !strings.Contains(err.Error(), "expects a finite number") {
+			t.Errorf("%q: got %v, want a finite number error", src, err)
+		}
+		if len(interp.Turtle.Lines) != 0 {
+			t.Errorf("%q: drew %d lines, want none", src, len(interp.Turtle.Lines))
+		}
+	}
+}
+
+func TestWritePNGScalesLargeDrawings(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	if _, err := interp.EvalString("forward(1000000)\nright(90)\nforward(10)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := interp.Turtle.WritePNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X > maxPNGSize || size.Y > maxPNGSize {
+		t.Errorf("got a %dx%d image, want at most %d pixels across", size.X, size.Y, maxPNGSize)
+	}
+}
+
+func TestWriteOverflowingDrawing(t *testing.T) {
+	// Each move is finite, but together they leave the range of float64
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	if _, err := interp.EvalString("forward(10.0 ^ 308)\nforward(10.0 ^ 308)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Turtle.WriteSVG(io.Discard); err != errDrawingTooLarge {
+		t.Errorf("WriteSVG: got %v, want %v", err, errDrawingTooLarge)
+	}
+	if err := interp.Turtle.WritePNG(io.Discard); err != errDrawingTooLarge {
+		t.Errorf("WritePNG: got %v, want %v", err, errDrawingTooLarge)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	if _, err := interp.EvalString("color(\"red\")\nforward(100)\nright(90)\nforward(50)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := interp.Turtle.WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="-10 -110 70 120" width="70" height="120">
+  <line x1="0" y1="0" x2="0" y2="-100" stroke="red" stroke-width="2" stroke-linecap="round"/>
+  <line x1="0" y1="-100" x2="50" y2="-100" stroke="red" stroke-width="2" stroke-linecap="round"/>
+</svg>
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
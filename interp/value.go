@@ -0,0 +1,180 @@
+package interp
+
+import (
+	"strconv"
+	"strings"
+
+	"turtle/ast"
+	"turtle/lexer"
+)
+
+// Value is a runtime value produced by evaluating an expression.
+type Value interface {
+	Kind() string   // Name of the value's kind, used in error messages
+	String() string // Printed form of the value
+}
+
+// Int is a whole number.
+type Int int64
+
+// Float is a floating-point number. Arithmetic that mixes an Int with a Float
+// promotes the Int to a Float.
+type Float float64
+
+// Bool is a truth value, produced by comparisons and logical operators.
+type Bool bool
+
+// String is a string of text.
+type String string
+
+// Kind returns "int".
+func (Int) Kind() string { return "int" }
+
+// Kind returns "float".
+func (Float) Kind() string { return "float" }
+
+// Kind returns "bool".
+func (Bool) Kind() string { return "bool" }
+
+// Kind returns "string".
+func (String) Kind() string { return "string" }
+
+// String formats the number in decimal.
+func (n Int) String() string {
+	return strconv.FormatInt(int64(n), 10)
+}
+
+// String formats the number with as many digits as it takes to tell it
+// apart, keeping a ".0" on whole numbers so that 4.0 does not print like the
+// Int 4.
+func (f Float) String() string {
+	s := strconv.FormatFloat(float64(f), 'f', -1, 64)
+	if !strings.ContainsAny(s, ".IN") {
+		s += ".0"
+	}
+	return s
+}
+
+// String returns "true" or "false".
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+// String returns the text itself, without quotes.
+func (s String) String() string {
+	return string(s)
+}
+
+// List is an ordered sequence of values. Lists are shared rather than
+// copied, so a list changed through one variable changes for every variable
+// holding it.
+type List struct {
+	Elems []Value
+}
+
+// Kind returns "list".
+func (*List) Kind() string { return "list" }
+
+// String formats the list as a literal, such as [1, "two", 3.0]. A list that
+// contains itself prints as [...] where it repeats.
+func (l *List) String() string {
+	return l.format(make(map[*List]bool))
+}
+
+// format formats the list, skipping the lists in printing, which are the
+// ones it is nested inside.
+func (l *List) format(printing map[*List]bool) string {
+	if printing[l] {
+		return "[...]"
+	}
+	printing[l] = true
+	defer delete(printing, l)
+
+	elems := make([]string, len(l.Elems))
+	for i, elem := range l.Elems {
+		switch elem := elem.(type) {
+		case String:
+			elems[i] = strconv.Quote(string(elem))
+		case *List:
+			elems[i] = elem.format(printing)
+		default:
+			elems[i] = elem.String()
+		}
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// isTruthy reports whether a value counts as true in a condition: true, any
+// non-zero number, or a non-empty string or list.
+func isTruthy(v Value) bool {
+	switch v := v.(type) {
+	case Bool:
+		return bool(v)
+	case Int:
+		return v != 0
+	case Float:
+		return v != 0
+	case String:
+		return v != ""
+	case *List:
+		return len(v.Elems) > 0
+	}
+	return false
+}
+
+// toFloat returns a numeric value as a float64, promoting an Int. It reports
+// false if v is not a number.
+func toFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v), true
+	case Float:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// valuesEqual reports whether two values are equal. An Int and a Float
+// compare by value; values of other differing kinds are never equal.
+func valuesEqual(a, b Value) bool {
+	x, xNumeric := toFloat(a)
+	y, yNumeric := toFloat(b)
+	if xNumeric && yNumeric && a.Kind() != b.Kind() {
+		return x == y
+	}
+	return a == b
+}
+
+// Nil is the value of a call to a function that returns nothing.
+type Nil struct{}
+
+// Kind returns "nil".
+func (Nil) Kind() string { return "nil" }
+
+// String returns "nil".
+func (Nil) String() string { return "nil" }
+
+// Function is a user-defined function together with the scope it was
+// defined in, which its body can see when it is called.
+type Function struct {
+	Decl    *ast.FuncStmt
+	Closure *Environment
+}
+
+// Kind returns "function".
+func (*Function) Kind() string { return "function" }
+
+// String returns the function's name, as in "<fn add>".
+func (f *Function) String() string { return "<fn " + f.Decl.Name.Value + ">" }
+
+// Builtin is a function implemented in Go, such as print.
+type Builtin struct {
+	Name string
+	Fn   func(interp *Interpreter, pos lexer.Position, args []Value) (Value, error)
+}
+
+// Kind returns "function".
+func (*Builtin) Kind() string { return "function" }
+
+// String returns the builtin's name, as in "<builtin print>".
+func (b *Builtin) String() string { return "<builtin " + b.Name + ">" }
@@ -0,0 +1,154 @@
+package interp
+
+import (
+	"fmt"
+
+	"turtle/ast"
+)
+
+// execCompiled compiles a statement and runs it on the VM. The scope is
+// restored afterwards even if the statement fails part of the way through a
+// block.
+func (interp *Interpreter) execCompiled(stmt ast.Stmt) error {
+	c, err := compile(stmt)
+	if err != nil {
+		return err
+	}
+
+	outer := interp.env
+	defer func() { interp.env = outer }()
+	_, err = interp.runChunk(c)
+	return err
+}
+
+// compiledBody returns the bytecode of a function's body, compiling it the
+// first time the function is called.
+func (interp *Interpreter) compiledBody(fn *ast.FuncStmt) (*chunk, error) {
+	if c, ok := interp.bodies[fn]; ok {
+		return c, nil
+	}
+	c, err := compileBody(fn)
+	if err != nil {
+		return nil, err
+	}
+	if interp.bodies == nil {
+		interp.bodies = make(map[*ast.FuncStmt]*chunk)
+	}
+	interp.bodies[fn] = c
+	return c, nil
+}
+
+// runChunk executes bytecode on a stack of values until it runs off the end
+// or returns, in which case the returned value is passed on.
+func (interp *Interpreter) runChunk(c *chunk) (Value, error) {
+	stack := make([]Value, 0, 16)
+	pop := func() Value {
+		value := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return value
+	}
+
+	for pc := 0; pc < len(c.code); pc++ {
+		in := c.code[pc]
+		switch in.Op {
+		case opConst:
+			stack = append(stack, c.constants[in.Arg])
+		case opGet:
+			value, err := interp.lookup(c.tokens[in.Arg])
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, value)
+		case opAssign:
+			interp.env.Assign(c.tokens[in.Arg].Value, pop(), c.sources[in.Arg])
+		case opDefine:
+			interp.env.Define(c.tokens[in.Arg].Value, pop(), c.sources[in.Arg])
+		case opPushScope:
+			interp.env = NewEnvironment(interp.env)
+		case opPopScope:
+			interp.env = interp.env.outer
+		case opUnary:
+			value, err := applyUnary(c.tokens[in.Arg], pop())
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, value)
+		case opBinary:
+			right := pop()
+			value, err := interp.applyOperator(c.tokens[in.Arg], pop(), right)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, value)
+		case opTruthy:
+			stack = append(stack, Bool(isTruthy(pop())))
+		case opJump:
+			pc = in.Arg - 1
+		case opJumpIfFalse:
+			if !isTruthy(pop()) {
+				pc = in.Arg - 1
+			}
+		case opJumpIfTrue:
+			if isTruthy(pop()) {
+				pc = in.Arg - 1
+			}
+		case opList:
+			elems := make([]Value, in.Arg)
+			copy(elems, stack[len(stack)-in.Arg:])
+			stack = stack[:len(stack)-in.Arg]
+			stack = append(stack, &List{Elems: elems})
+		case opIndex:
+			index := pop()
+			list, i, err := indexList(c.indexes[in.Arg], pop(), index)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, list.Elems[i])
+		case opSetIndex:
+			value, index := pop(), pop()
+			list, i, err := indexList(c.indexes[in.Arg], pop(), index)
+			if err != nil {
+				return nil, err
+			}
+			list.Elems[i] = value
+		case opCall:
+			call := c.calls[in.Arg]
+			args := make([]Value, len(call.Args))
+			copy(args, stack[len(stack)-len(args):])
+			stack = stack[:len(stack)-len(args)]
+			value, err := interp.call(call, pop(), args)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, value)
+		case opFunc:
+			fn := c.funcs[in.Arg]
+			interp.env.Define(fn.Name.Value, &Function{Decl: fn, Closure: interp.env}, "")
+		case opReturn:
+			return pop(), nil
+		case opPrint:
+			value := pop()
+			interp.last = value
+
+			// A call that returns nothing prints nothing
+			if _, isNil := value.(Nil); !isNil {
+				fmt.Fprintln(interp.Out, value.String())
+			}
+		case opSleep:
+			if err := interp.sleep(in.Pos, pop()); err != nil {
+				return nil, err
+			}
+		case opStep:
+			if err := interp.step(in.Pos); err != nil {
+				return nil, err
+			}
+		case opImport:
+			if err := interp.importFile(c.imports[in.Arg]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, &RuntimeError{Pos: in.Pos, Msg: fmt.Sprintf("unknown opcode %d", in.Op)}
+		}
+	}
+	return Nil{}, nil
+}
@@ -0,0 +1,89 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"turtle/lexer"
+	"turtle/parser"
+)
+
+// agreementPrograms are run on both backends by TestBackendsAgree, which
+// expects the same output and errors from each.
+var agreementPrograms = []string{
+	"1 + 2 * 3\n(1 + 2) * 3\n10 / 4\n10 / 5\n7 % 3\n2 ^ 3 ^ 2\n-5 + 1.5",
+	`name = "turtle"` + "\n" + `"hello, " + name` + "\n" + `print("a", 1, 2.0, true)`,
+	"a = 1 < 2 && 2 < 3\nb = false || !a\na\nb\n1 == 1.0\n\"x\" != \"y\"",
+	"x = 5\nif x > 3 then print(\"big\") else print(\"small\")\nif x < 3 { print(1) } else { print(2) }",
+	"i = 0\ntotal = 0\nwhile i < 10 {\n  total = total + i\n  i = i + 1\n}\ntotal",
+	"x = 1\n{\n  x = 2\n  y = 3\n}\nx\ny",
+	"fn fact(n) {\n  if n <= 1 { return 1 }\n  return n * fact(n - 1)\n}\nfact(10)",
+	"fn counter() {\n  n = 0\n  fn next() {\n    n = n + 1\n    return n\n  }\n  return next\n}\nc = counter()\nc()\nc()\nc",
+	"fn nothing() { return }\nnothing()\nprint(nothing())",
+	"xs = [1, \"two\", 3.0]\nxs[1]\nxs[0] = 10\npush(xs, [4])\nxs\nlen(xs)\nlen(\"héllo\")",
+	"let t = 2 in t * 3\nt",
+	"false && print(\"not printed\")\ntrue || print(\"not printed\")\ntrue && print(\"printed\")",
+
+	// Errors, and the side effects of the operands evaluated before them
+	"1 / 0\n5 % 0\nmissing + 1\n\"a\" - 1\n-\"a\"",
+	"5[print(\"index\")]\nxs = [1]\nxs[3] = print(\"value\")\nxs[print(\"index\")] = 2\nxs[0.5]",
+	"fn f(a, b) { return a }\nf(1)\n5(print(\"arg\"))\nnothing(print(\"arg\"))",
+	"x = 1; y = x + 1; y // statements on one line",
+	"sleep \"long\"\nforward(\"far\")\ncolor(\"nope\")",
+}
+
+func TestBackendsAgree(t *testing.T) {
+	for _, src := range agreementPrograms {
+		tree, vm := runCollecting(src, false), runCollecting(src, true)
+		if tree != vm {
+			t.Errorf("%q:\ntree walker:\n%s\nvm:\n%s", src, tree, vm)
+		}
+	}
+}
+
+// runCollecting runs src, carrying on after errors, and returns what it
+// printed with each error on a line of its own.
+func runCollecting(src string, vm bool) string {
+	var out bytes.Buffer
+	interp := NewInterpreter()
+	interp.VM = vm
+	interp.Out = &out
+	interp.Sleep = func(context.Context, time.Duration) error { return nil }
+	interp.OnError = func(err error) {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	if err := interp.Run(context.Background(), parser.NewParser(lexer.NewLexerFromString(src))); err != nil {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	return out.String()
+}
+
+// benchmarkPrograms are the programs of build/bench.tl, run separately.
+var benchmarkPrograms = []struct {
+	name string
+	src  string
+}{
+	{name: "fib", src: "fn fib(n) {\n  if n < 2 { return n }\n  return fib(n - 1) + fib(n - 2)\n}\nfib(20)"},
+	{name: "loop", src: "i = 0\ntotal = 0\nwhile i < 50000 {\n  total = total + i % 7 * 2\n  i = i + 1\n}\ntotal"},
+}
+
+func BenchmarkBackends(b *testing.B) {
+	for _, program := range benchmarkPrograms {
+		for _, backend := range backends {
+			b.Run(program.name+"/"+backend.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					interp := NewInterpreter()
+					interp.VM = backend.vm
+					interp.Out = io.Discard
+					if _, err := interp.EvalString(program.src); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
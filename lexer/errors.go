@@ -0,0 +1,37 @@
+package lexer
+
+import "fmt"
+
+// Position identifies where in the input a token came from.
+type Position struct {
+	Line   int // 1-based line number
+	Column int // 1-based column, counted in runes; 0 if unknown
+}
+
+// String formats the position for error messages.
+func (p Position) String() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("line %d", p.Line)
+	}
+	return fmt.Sprintf("line %d, col %d", p.Line, p.Column)
+}
+
+// Error is implemented by every error the interpreter reports, so callers can
+// recover the position regardless of the error category.
+type Error interface {
+	error
+	Position() Position
+}
+
+// LexError reports input that could not be turned into a valid token.
+type LexError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("lex error at %s: %s", e.Pos, e.Msg)
+}
+
+// Position returns where the error occurred.
+func (e *LexError) Position() Position { return e.Pos }
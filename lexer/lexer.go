@@ -0,0 +1,303 @@
+// Package lexer turns Turtle source into a stream of tokens.
+package lexer
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token represents a token in the input stream.
+type Token struct {
+	Type  string   // Type of the token (e.g., "NUMBER", "PLUS", "IDENT", "ASSIGN", "EOF")
+	Value string   // Value of the token (e.g., "42", "+", "x", "=")
+	Pos   Position // Position of the token in the input
+}
+
+// String formats the token as its type followed by its quoted text, such as
+// IDENT "x", leaving out the text of tokens that have none.
+func (t Token) String() string {
+	if t.Value == "" {
+		return t.Type
+	}
+	return t.Type + " " + strconv.Quote(t.Value)
+}
+
+// Lexer scans the input string and produces tokens.
+type Lexer struct {
+	scanner *bufio.Scanner
+	tokens  []Token
+	line    int // Number of the line currently being tokenized
+	width   int // Number of runes on that line
+	depth   int // Number of parentheses and brackets opened but not yet closed
+	braces  int // Number of braces opened but not yet closed
+	err     error
+}
+
+// NewLexer creates a new lexer that tokenizes everything read from r, such as
+// a file or os.Stdin. If reading fails part of the way through, Err reports
+// why.
+func NewLexer(r io.Reader) *Lexer {
+	lexer := &Lexer{
+		scanner: bufio.NewScanner(r),
+		tokens:  make([]Token, 0),
+	}
+	lexer.tokenizeInput()
+	return lexer
+}
+
+// NewLexerFromReader creates a new lexer that tokenizes everything read from r.
+//
+// Deprecated: Use NewLexer, which takes a reader itself.
+func NewLexerFromReader(r io.Reader) *Lexer {
+	return NewLexer(r)
+}
+
+// NewLexerFromFile creates a new lexer with the given input file.
+func NewLexerFromFile(filename string) (*Lexer, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lexer := NewLexer(file)
+	if err := lexer.Err(); err != nil {
+		return nil, err
+	}
+	return lexer, nil
+}
+
+// NewLexerFromString creates a new lexer that tokenizes the given source.
+func NewLexerFromString(s string) *Lexer {
+	return NewLexer(strings.NewReader(s))
+}
+
+// tokenizeInput scans the input and tokenizes it line by line.
+func (l *Lexer) tokenizeInput() {
+	for l.scanner.Scan() {
+		l.AddLine(l.scanner.Text())
+	}
+	if err := l.scanner.Err(); err != nil {
+		l.err = &LexError{Pos: Position{Line: l.line + 1, Column: 1}, Msg: err.Error()}
+	}
+}
+
+// Err returns the error, if any, that stopped the lexer from reading all of
+// its input.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// AddLine tokenizes one more line of input and appends its tokens to the
+// stream, so input can keep arriving after the lexer was created.
+func (l *Lexer) AddLine(line string) {
+	l.line++
+	l.width = utf8.RuneCountInString(line)
+	tokens := l.tokenizeLine(line)
+	l.tokens = append(l.tokens, tokens...)
+}
+
+// tokenizeLine tokenizes a single line of input character by character, so
+// tokens do not need to be separated by whitespace. A # or // outside a
+// string literal starts a comment that runs to the end of the line. Lines
+// with any tokens end in a NEWLINE token, unless a parenthesis is still open,
+// in which case the expression continues on the next line; blank and
+// comment-only lines produce nothing. A semicolon ends a statement in the
+// middle of a line, so it is a NEWLINE token too.
+func (l *Lexer) tokenizeLine(line string) []Token {
+	tokens := make([]Token, 0)
+	runes := []rune(line)
+
+	for i := 0; i < len(runes); {
+		start := i
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			// Skip whitespace between tokens
+			i++
+			continue
+		case r == '#' || r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			// Skip the comment at the end of the line
+			i = len(runes)
+			continue
+		case r == '"':
+			// Accumulate a string literal up to its closing quote, skipping
+			// over escaped characters
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(runes) {
+				i++ // Include the closing quote
+			}
+		case unicode.IsDigit(r):
+			// Accumulate a run of digits, with an optional decimal point, into a number
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+		case unicode.IsLetter(r):
+			// Accumulate letters, digits and underscores into a word
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+		default:
+			// Every other character is a token of its own, except for the
+			// two-character operators ==, !=, <=, >=, && and ||
+			i++
+			if i < len(runes) && runes[i] == '=' && strings.ContainsRune("=!<>", r) {
+				i++
+			} else if i < len(runes) && runes[i] == r && strings.ContainsRune("&|", r) {
+				i++
+			}
+		}
+
+		tokenText := string(runes[start:i])
+		tokenType := l.getTokenType(tokenText)
+		token := Token{Type: tokenType, Value: tokenText, Pos: Position{Line: l.line, Column: start + 1}}
+		tokens = append(tokens, token)
+
+		switch {
+		case tokenType == "LPAREN" || tokenType == "LBRACKET":
+			l.depth++
+		case (tokenType == "RPAREN" || tokenType == "RBRACKET") && l.depth > 0:
+			l.depth--
+		case tokenType == "LBRACE":
+			l.braces++
+		case tokenType == "RBRACE" && l.braces > 0:
+			l.braces--
+		}
+	}
+
+	if len(tokens) > 0 && l.depth == 0 {
+		tokens = append(tokens, Token{Type: "NEWLINE", Value: "\n", Pos: Position{Line: l.line, Column: len(runes) + 1}})
+	}
+
+	return tokens
+}
+
+// getTokenType determines the type of the token.
+func (l *Lexer) getTokenType(tokenText string) string {
+	switch tokenText {
+	case "+":
+		return "PLUS"
+	case "-":
+		return "MINUS"
+	case "*":
+		return "MULTIPLY"
+	case "/":
+		return "DIVIDE"
+	case "%":
+		return "MODULO"
+	case "^":
+		return "POWER"
+	case "=":
+		return "ASSIGN"
+	case ";":
+		return "NEWLINE"
+	case "(":
+		return "LPAREN"
+	case ")":
+		return "RPAREN"
+	case "[":
+		return "LBRACKET"
+	case "]":
+		return "RBRACKET"
+	case ",":
+		return "COMMA"
+	case "{":
+		return "LBRACE"
+	case "}":
+		return "RBRACE"
+	case "==":
+		return "EQ"
+	case "!=":
+		return "NEQ"
+	case "<":
+		return "LT"
+	case ">":
+		return "GT"
+	case "<=":
+		return "LTE"
+	case ">=":
+		return "GTE"
+	case "&&":
+		return "AND"
+	case "||":
+		return "OR"
+	case "!":
+		return "NOT"
+	case "sleep":
+		return "SLEEP"
+	case "import":
+		return "IMPORT"
+	case "if":
+		return "IF"
+	case "while":
+		return "WHILE"
+	case "fn":
+		return "FN"
+	case "return":
+		return "RETURN"
+	case "then":
+		return "THEN"
+	case "else":
+		return "ELSE"
+	case "let":
+		return "LET"
+	case "in":
+		return "IN"
+	case "true":
+		return "TRUE"
+	case "false":
+		return "FALSE"
+	default:
+		if len(tokenText) >= 2 && tokenText[0] == '"' && tokenText[len(tokenText)-1] == '"' {
+			return "STRING"
+		}
+		if _, err := strconv.ParseFloat(tokenText, 64); err == nil && unicode.IsDigit(rune(tokenText[0])) {
+			if strings.ContainsRune(tokenText, '.') {
+				return "FLOAT"
+			}
+			return "NUMBER"
+		} else if unicode.IsLetter(rune(tokenText[0])) {
+			return "IDENT"
+		}
+	}
+	return "UNKNOWN"
+}
+
+// Depth returns the number of parentheses and braces left open by the input
+// so far. A non-zero depth means the current statement continues on the next
+// line.
+func (l *Lexer) Depth() int {
+	return l.depth + l.braces
+}
+
+// NextToken returns the next token in the input stream.
+func (l *Lexer) NextToken() Token {
+	if len(l.tokens) == 0 {
+		return l.eof()
+	}
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+	return token
+}
+
+// PeekToken returns the next token without removing it from the stream.
+func (l *Lexer) PeekToken() Token {
+	if len(l.tokens) == 0 {
+		return l.eof()
+	}
+	return l.tokens[0]
+}
+
+// eof returns the token at the end of the input, which is positioned just
+// after the last character of the last line so errors there have a column.
+func (l *Lexer) eof() Token {
+	return Token{Type: "EOF", Value: "", Pos: Position{Line: max(l.line, 1), Column: l.width + 1}}
+}
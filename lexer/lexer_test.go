@@ -0,0 +1,102 @@
+package lexer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokenTypes drains lex and returns the types of its tokens, up to but not
+// including EOF.
+func tokenTypes(lex *Lexer) []string {
+	var types []string
+	for token := lex.NextToken(); token.Type != "EOF"; token = lex.NextToken() {
+		types = append(types, token.Type)
+	}
+	return types
+}
+
+func TestNewLexerReadsFromReader(t *testing.T) {
+	// A reader that hands over one byte at a time splits lines and tokens
+	// across reads
+	r := iotest.OneByteReader(strings.NewReader("x = 2 * (3 +\n4)\nprint(x) # comment\n"))
+	lex := NewLexer(r)
+	if err := lex.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"IDENT", "ASSIGN", "NUMBER", "MULTIPLY", "LPAREN", "NUMBER", "PLUS", "NUMBER", "RPAREN", "NEWLINE",
+		"IDENT", "LPAREN", "IDENT", "RPAREN", "NEWLINE",
+	}
+	if got := tokenTypes(lex); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewLexerFromReaderMatchesNewLexer(t *testing.T) {
+	src := "a = [1, 2.5, \"three\"]; a[0]\n"
+	got, want := tokenTypes(NewLexerFromReader(strings.NewReader(src))), tokenTypes(NewLexer(strings.NewReader(src)))
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewLexerReportsReadError(t *testing.T) {
+	failure := errors.New("disk on fire")
+	lex := NewLexer(io.MultiReader(strings.NewReader("x = 1\n"), iotest.ErrReader(failure)))
+
+	var lexErr *LexError
+	if err := lex.Err(); !errors.As(err, &lexErr) || lexErr.Msg != failure.Error() {
+		t.Fatalf("got %v, want a LexError saying %q", err, failure)
+	}
+	if lexErr.Pos.Line != 2 {
+		t.Errorf("got line %d, want 2", lexErr.Pos.Line)
+	}
+
+	// The lines read before the failure are still tokenized
+	if got := tokenTypes(lex); strings.Join(got, " ") != "IDENT ASSIGN NUMBER NEWLINE" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "x = 1 # set x", want: "IDENT ASSIGN NUMBER NEWLINE"},
+		{src: "x = 1 // set x", want: "IDENT ASSIGN NUMBER NEWLINE"},
+		{src: "# a whole line\n  // and another\n", want: ""},
+		{src: "x=1#no spaces", want: "IDENT ASSIGN NUMBER NEWLINE"},
+		{src: `"# not a comment" # but this is`, want: "STRING NEWLINE"},
+		{src: "10 / 2 # division, not a comment", want: "NUMBER DIVIDE NUMBER NEWLINE"},
+		{src: "f(1, # the first\n2)", want: "IDENT LPAREN NUMBER COMMA NUMBER RPAREN NEWLINE"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(tokenTypes(NewLexerFromString(test.src)), " "); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestEOFPosition(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Position
+	}{
+		{src: "", want: Position{Line: 1, Column: 1}},
+		{src: "(1 +", want: Position{Line: 1, Column: 5}},
+		{src: "x = 1\nprint(\"é\", ", want: Position{Line: 2, Column: 12}},
+		{src: "x = 1\n", want: Position{Line: 1, Column: 6}},
+	}
+	for _, test := range tests {
+		lex := NewLexerFromString(test.src)
+		tokenTypes(lex)
+		if peeked, next := lex.PeekToken(), lex.NextToken(); peeked.Pos != test.want || next.Pos != test.want {
+			t.Errorf("%q: EOF at %v and %v, want %v", test.src, peeked.Pos, next.Pos, test.want)
+		}
+	}
+}
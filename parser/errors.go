@@ -0,0 +1,20 @@
+package parser
+
+import (
+	"fmt"
+
+	"turtle/lexer"
+)
+
+// ParseError reports a token sequence that does not form a valid statement.
+type ParseError struct {
+	Pos lexer.Position
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at %s: %s", e.Pos, e.Msg)
+}
+
+// Position returns where the error occurred.
+func (e *ParseError) Position() lexer.Position { return e.Pos }
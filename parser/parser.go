@@ -0,0 +1,723 @@
+// Package parser builds a syntax tree from the tokens of a lexer.
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"turtle/ast"
+	"turtle/lexer"
+)
+
+// Parser represents a recursive descent parser that turns the token stream
+// into statements for the Interpreter to run.
+type Parser struct {
+	lexer    *lexer.Lexer
+	curToken lexer.Token
+	blocks   int // Number of blocks the current token is nested in
+	funcs    int // Number of function bodies the current token is nested in
+	parens   int // Number of parentheses and brackets the current token is nested in
+
+	// The lexer leaves out the line breaks inside parentheses. continued is
+	// set when one was left out before the current token, and held is a
+	// token held back behind a NEWLINE put in its place, which happens once
+	// the parentheses were closed or skipped over by error recovery.
+	continued bool
+	held      *lexer.Token
+}
+
+// NewParser creates a new parser with the given lexer.
+func NewParser(lexer *lexer.Lexer) *Parser {
+	parser := &Parser{
+		lexer: lexer,
+	}
+	parser.consumeToken() // Initialize current token
+	return parser
+}
+
+// Feed appends a line of input to the parser's token stream, so an
+// interactive session can keep parsing after the input was exhausted.
+func (p *Parser) Feed(line string) {
+	p.lexer.AddLine(line)
+	if p.curToken.Type == "EOF" {
+		p.consumeToken()
+	}
+}
+
+// consumeToken advances to the next token in the input stream. A line
+// break the lexer left out because a parenthesis was open, which this
+// statement has not left open, ends the line after all.
+func (p *Parser) consumeToken() {
+	switch p.curToken.Type {
+	case "LPAREN", "LBRACKET":
+		p.parens++
+	case "RPAREN", "RBRACKET":
+		if p.parens > 0 {
+			p.parens--
+		}
+	}
+
+	prev := p.curToken
+	if p.held != nil {
+		p.curToken, p.held, p.continued = *p.held, nil, false
+		return
+	}
+	p.curToken = p.lexer.NextToken()
+	p.continued = prev.Type != "" && prev.Type != "NEWLINE" && prev.Type != "EOF" && p.curToken.Pos.Line > prev.Pos.Line
+	if p.continued && p.parens == 0 {
+		held := p.curToken
+		p.held = &held
+		p.curToken = lexer.Token{Type: "NEWLINE", Value: "\n", Pos: lexer.Position{Line: prev.Pos.Line, Column: prev.Pos.Column + utf8.RuneCountInString(prev.Value)}}
+	}
+}
+
+// ParseStatement parses the next statement, which must take up the rest of its
+// line. It returns a nil statement once the input is exhausted. After an
+// error the rest of the line is skipped, so parsing can carry on with the
+// next statement.
+func (p *Parser) ParseStatement() (ast.Stmt, error) {
+	// Blank lines have nothing to parse
+	for p.curToken.Type == "NEWLINE" {
+		p.consumeToken()
+	}
+	if p.curToken.Type == "EOF" {
+		return nil, nil
+	}
+
+	stmt, err := p.parseStatement()
+	if err == nil && p.curToken.Type != "NEWLINE" && p.curToken.Type != "EOF" {
+		err = p.unexpected()
+	}
+	p.skipLine()
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// skipLine discards what is left of the current line, including the NEWLINE
+// token. A line inside a block is skipped through the block's closing brace,
+// so that an error in a block skips the whole statement it belongs to. Inside
+// an unclosed parenthesis the skip stops at the first token on a later line,
+// unless the line before ends in a way that says it continues, so that one
+// missing ) does not swallow the rest of the input.
+func (p *Parser) skipLine() {
+	depth := p.blocks
+	line := p.curToken.Pos.Line
+	p.blocks, p.funcs = 0, 0
+	defer func() { p.parens = 0 }()
+	last := ""
+	for p.curToken.Type != "EOF" {
+		if p.continued && p.parens > 0 && p.curToken.Pos.Line > line && !continuesLine(last) {
+			return
+		}
+		last = p.curToken.Type
+		switch p.curToken.Type {
+		case "LBRACE":
+			depth++
+		case "RBRACE":
+			depth--
+		}
+		newline := p.curToken.Type == "NEWLINE" && depth <= 0
+		p.consumeToken()
+		if newline {
+			return
+		}
+	}
+}
+
+// continuesLine reports whether a line ending in a token of the given type
+// is meant to continue on the next line: one ending in a comma, an operator
+// or an opening parenthesis or bracket.
+func continuesLine(tokenType string) bool {
+	switch tokenType {
+	case "COMMA", "LPAREN", "LBRACKET", "PLUS", "MINUS", "MULTIPLY", "DIVIDE", "MODULO", "POWER",
+		"EQ", "NEQ", "LT", "GT", "LTE", "GTE", "AND", "OR", "NOT", "ASSIGN":
+		return true
+	}
+	return false
+}
+
+// parseStatement parses a statement (variable or element assignment, if,
+// while, sleep, function definition, return, block or expression).
+func (p *Parser) parseStatement() (ast.Stmt, error) {
+	switch {
+	case p.curToken.Type == "LBRACE":
+		block, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		return block, nil
+	case p.curToken.Type == "IF":
+		return p.parseIf()
+	case p.curToken.Type == "WHILE":
+		return p.parseWhile()
+	case p.curToken.Type == "FN":
+		return p.parseFunc()
+	case p.curToken.Type == "RETURN":
+		return p.parseReturn()
+	case p.curToken.Type == "IMPORT":
+		return p.parseImport()
+	case p.curToken.Type == "SLEEP":
+		// Pause for the given number of milliseconds
+		sleepToken := p.curToken
+		p.consumeToken() // Consume SLEEP token
+		duration, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.SleepStmt{Sleep: sleepToken, Duration: duration}, nil
+	case p.curToken.Type == "IDENT" && p.lexer.PeekToken().Type == "ASSIGN":
+		// Variable assignment
+		nameToken := p.curToken
+		p.consumeToken() // Consume variable name
+		p.consumeToken() // Consume ASSIGN token
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AssignStmt{Name: nameToken, Value: value}, nil
+	default:
+		// Expression statement
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.curToken.Type != "ASSIGN" {
+			return &ast.ExprStmt{Expr: expr}, nil
+		}
+
+		// Element assignment
+		target, ok := expr.(*ast.IndexExpr)
+		if !ok {
+			return nil, &ParseError{Pos: p.curToken.Pos, Msg: fmt.Sprintf("cannot assign to %s", expr)}
+		}
+		p.consumeToken() // Consume ASSIGN token
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.IndexAssignStmt{Target: target, Value: value}, nil
+	}
+}
+
+// parseIf parses an if statement, either if <cond> then <stmt> or
+// if <cond> { ... }; the else branch is optional and must start on the line
+// the then branch ends on.
+func (p *Parser) parseIf() (ast.Stmt, error) {
+	stmt := &ast.IfStmt{If: p.curToken}
+	p.consumeToken() // Consume IF token
+
+	var err error
+	if stmt.Cond, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if p.curToken.Type == "LBRACE" {
+		stmt.Then, err = p.parseBlock()
+	} else if _, err = p.expect("THEN"); err == nil {
+		stmt.Then, err = p.parseStatement()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.curToken.Type == "ELSE" {
+		p.consumeToken() // Consume ELSE token
+		if stmt.Else, err = p.parseStatement(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseWhile parses a while loop, while <cond> { ... }.
+func (p *Parser) parseWhile() (ast.Stmt, error) {
+	stmt := &ast.WhileStmt{While: p.curToken}
+	p.consumeToken() // Consume WHILE token
+
+	var err error
+	if stmt.Cond, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if stmt.Body, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseFunc parses a function definition, fn <name>(<params>) { ... }.
+func (p *Parser) parseFunc() (ast.Stmt, error) {
+	stmt := &ast.FuncStmt{Fn: p.curToken}
+	p.consumeToken() // Consume FN token
+
+	var err error
+	if stmt.Name, err = p.expect("IDENT"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("LPAREN"); err != nil {
+		return nil, err
+	}
+	for p.curToken.Type != "RPAREN" {
+		if len(stmt.Params) > 0 {
+			if _, err := p.expect("COMMA"); err != nil {
+				return nil, err
+			}
+		}
+		param, err := p.expect("IDENT")
+		if err != nil {
+			return nil, err
+		}
+		stmt.Params = append(stmt.Params, param)
+	}
+	p.consumeToken() // Consume RPAREN token
+
+	p.funcs++
+	if stmt.Body, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	p.funcs--
+	return stmt, nil
+}
+
+// parseReturn parses a return statement, whose value is optional. It may only
+// appear inside a function body.
+func (p *Parser) parseReturn() (ast.Stmt, error) {
+	stmt := &ast.ReturnStmt{Return: p.curToken}
+	if p.funcs == 0 {
+		return nil, &ParseError{Pos: p.curToken.Pos, Msg: "return outside function"}
+	}
+	p.consumeToken() // Consume RETURN token
+
+	switch p.curToken.Type {
+	case "NEWLINE", "RBRACE", "EOF":
+		return stmt, nil
+	}
+	var err error
+	if stmt.Value, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseImport parses an import statement, whose path must be a string
+// literal.
+func (p *Parser) parseImport() (ast.Stmt, error) {
+	stmt := &ast.ImportStmt{Import: p.curToken}
+	p.consumeToken() // Consume IMPORT token
+
+	token, err := p.expect("STRING")
+	if err != nil {
+		return nil, err
+	}
+	path, err := strconv.Unquote(token.Value)
+	if err != nil {
+		return nil, &lexer.LexError{Pos: token.Pos, Msg: fmt.Sprintf("invalid string %s", token.Value)}
+	}
+	stmt.Path = &ast.StringLit{Token: token, Value: path}
+	return stmt, nil
+}
+
+// parseBlock parses a brace-delimited block of statements, each on a line of
+// its own.
+func (p *Parser) parseBlock() (*ast.BlockStmt, error) {
+	lbrace, err := p.expect("LBRACE")
+	if err != nil {
+		return nil, err
+	}
+	block := &ast.BlockStmt{Lbrace: lbrace}
+	p.blocks++
+
+	for {
+		// Blank lines have nothing to parse
+		for p.curToken.Type == "NEWLINE" {
+			p.consumeToken()
+		}
+		if p.curToken.Type == "RBRACE" || p.curToken.Type == "EOF" {
+			break
+		}
+
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		if p.curToken.Type != "NEWLINE" && p.curToken.Type != "RBRACE" {
+			return nil, p.unexpected()
+		}
+		block.Stmts = append(block.Stmts, stmt)
+	}
+
+	// Ensure a matching right brace
+	if _, err := p.expect("RBRACE"); err != nil {
+		return nil, err
+	}
+	p.blocks--
+	return block, nil
+}
+
+// parseExpression parses an expression, starting with the logical OR
+// operator, which binds loosest of all.
+func (p *Parser) parseExpression() (ast.Expr, error) {
+	// Parse the first operand
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.curToken.Type == "OR" {
+		// Store the operator
+		operator := p.curToken
+		p.consumeToken()
+
+		// Parse the next operand
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{Op: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses a logical AND, which binds tighter than OR.
+func (p *Parser) parseAnd() (ast.Expr, error) {
+	// Parse the first operand
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.curToken.Type == "AND" {
+		// Store the operator
+		operator := p.curToken
+		p.consumeToken()
+
+		// Parse the next operand
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{Op: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseComparison parses the comparison operators, which bind tighter than
+// the logical operators and looser than arithmetic.
+func (p *Parser) parseComparison() (ast.Expr, error) {
+	// Parse the first operand
+	left, err := p.parseAddSub()
+	if err != nil {
+		return nil, err
+	}
+
+	for isComparison(p.curToken.Type) {
+		// Store the operator
+		operator := p.curToken
+		p.consumeToken()
+
+		// Parse the next operand
+		right, err := p.parseAddSub()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{Op: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// isComparison reports whether the token type is a comparison operator.
+func isComparison(tokenType string) bool {
+	switch tokenType {
+	case "EQ", "NEQ", "LT", "GT", "LTE", "GTE":
+		return true
+	}
+	return false
+}
+
+// parseAddSub parses an additive expression (PLUS and MINUS).
+func (p *Parser) parseAddSub() (ast.Expr, error) {
+	// Parse the first operand
+	left, err := p.parseMulDiv()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.curToken.Type == "PLUS" || p.curToken.Type == "MINUS" {
+		// Store the operator
+		operator := p.curToken
+		p.consumeToken()
+
+		// Parse the next operand
+		right, err := p.parseMulDiv()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{Op: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseMulDiv parses a multiplicative expression (MULTIPLY, DIVIDE and
+// MODULO), which binds tighter than addition and subtraction.
+func (p *Parser) parseMulDiv() (ast.Expr, error) {
+	// Parse the first operand
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.curToken.Type == "MULTIPLY" || p.curToken.Type == "DIVIDE" || p.curToken.Type == "MODULO" {
+		// Store the operator
+		operator := p.curToken
+		p.consumeToken()
+
+		// Parse the next operand
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{Op: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses a power preceded by any number of unary MINUS, PLUS or
+// NOT operators, so that -5, 3 * -2, 2 - -3 and !done all work.
+func (p *Parser) parseUnary() (ast.Expr, error) {
+	if p.curToken.Type != "MINUS" && p.curToken.Type != "PLUS" && p.curToken.Type != "NOT" {
+		return p.parsePower()
+	}
+
+	operator := p.curToken
+	p.consumeToken() // Consume MINUS, PLUS or NOT token
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.UnaryExpr{Op: operator, Operand: operand}, nil
+}
+
+// parsePower parses an exponentiation (POWER), which binds tighter than the
+// unary signs and is right-associative, so 2 ^ 3 ^ 2 is 2 ^ 9.
+func (p *Parser) parsePower() (ast.Expr, error) {
+	base, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.curToken.Type != "POWER" {
+		return base, nil
+	}
+	operator := p.curToken
+	p.consumeToken() // Consume POWER token
+
+	// The exponent may itself be signed or another exponentiation
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.BinaryExpr{Op: operator, Left: base, Right: exponent}, nil
+}
+
+// parsePostfix parses a term followed by any number of argument lists and
+// indexes, so that add(1, 2) calls add and xs[0] reads the first element of xs.
+func (p *Parser) parsePostfix() (ast.Expr, error) {
+	expr, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.curToken.Type {
+		case "LPAREN":
+			call := &ast.CallExpr{Callee: expr, Lparen: p.curToken}
+			p.consumeToken() // Consume LPAREN token
+			if call.Args, err = p.parseExprList("RPAREN"); err != nil {
+				return nil, err
+			}
+			expr = call
+		case "LBRACKET":
+			index := &ast.IndexExpr{Target: expr, Lbrack: p.curToken}
+			p.consumeToken() // Consume LBRACKET token
+			if index.Index, err = p.parseExpression(); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("RBRACKET"); err != nil {
+				return nil, err
+			}
+			expr = index
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// parseExprList parses comma-separated expressions up to and including the
+// closing token, allowing a trailing comma.
+func (p *Parser) parseExprList(closing string) ([]ast.Expr, error) {
+	var exprs []ast.Expr
+	for p.curToken.Type != closing {
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if p.curToken.Type != "COMMA" {
+			break
+		}
+		p.consumeToken() // Consume COMMA token
+	}
+
+	if _, err := p.expect(closing); err != nil {
+		return nil, err
+	}
+	return exprs, nil
+}
+
+// parseTerm parses a term (number, boolean, string, list, variable reference,
+// parentheses or let expression).
+func (p *Parser) parseTerm() (ast.Expr, error) {
+	switch p.curToken.Type {
+	case "NUMBER":
+		// Parse the number
+		number, err := strconv.ParseInt(p.curToken.Value, 10, 64)
+		if err != nil {
+			return nil, &lexer.LexError{Pos: p.curToken.Pos, Msg: fmt.Sprintf("invalid number %q", p.curToken.Value)}
+		}
+		lit := &ast.IntLit{Token: p.curToken, Value: number}
+
+		// Consume the NUMBER token
+		p.consumeToken()
+
+		return lit, nil
+	case "FLOAT":
+		// Parse the number
+		number, err := strconv.ParseFloat(p.curToken.Value, 64)
+		if err != nil {
+			return nil, &lexer.LexError{Pos: p.curToken.Pos, Msg: fmt.Sprintf("invalid number %q", p.curToken.Value)}
+		}
+		lit := &ast.FloatLit{Token: p.curToken, Value: number}
+
+		// Consume the FLOAT token
+		p.consumeToken()
+
+		return lit, nil
+	case "STRING":
+		value, err := strconv.Unquote(p.curToken.Value)
+		if err != nil {
+			return nil, &lexer.LexError{Pos: p.curToken.Pos, Msg: fmt.Sprintf("invalid string %s", p.curToken.Value)}
+		}
+		lit := &ast.StringLit{Token: p.curToken, Value: value}
+		p.consumeToken() // Consume STRING token
+		return lit, nil
+	case "TRUE", "FALSE":
+		lit := &ast.BoolLit{Token: p.curToken, Value: p.curToken.Type == "TRUE"}
+		p.consumeToken() // Consume TRUE or FALSE token
+		return lit, nil
+	case "IDENT":
+		// Variable reference
+		ident := &ast.Ident{Token: p.curToken}
+		p.consumeToken() // Consume variable name
+		return ident, nil
+	case "LPAREN":
+		// Consume the left parenthesis
+		paren := &ast.ParenExpr{Lparen: p.curToken}
+		p.consumeToken()
+
+		// Parse the expression inside the parentheses
+		inner, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		paren.Inner = inner
+
+		// Ensure a matching right parenthesis
+		if _, err := p.expect("RPAREN"); err != nil {
+			return nil, err
+		}
+
+		return paren, nil
+	case "LBRACKET":
+		list := &ast.ListLit{Lbrack: p.curToken}
+		p.consumeToken() // Consume LBRACKET token
+
+		var err error
+		if list.Elems, err = p.parseExprList("RBRACKET"); err != nil {
+			return nil, err
+		}
+		return list, nil
+	case "LET":
+		return p.parseLet()
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// parseLet parses a let expression, let <name> = <expr> in <expr>.
+func (p *Parser) parseLet() (ast.Expr, error) {
+	let := &ast.LetExpr{Let: p.curToken}
+	p.consumeToken() // Consume LET token
+
+	var err error
+	if let.Name, err = p.expect("IDENT"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("ASSIGN"); err != nil {
+		return nil, err
+	}
+	if let.Value, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("IN"); err != nil {
+		return nil, err
+	}
+	if let.Body, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	return let, nil
+}
+
+// expect consumes the current token if it has the given type and reports a
+// parse error otherwise.
+func (p *Parser) expect(tokenType string) (lexer.Token, error) {
+	token := p.curToken
+	if token.Type != tokenType {
+		return token, &ParseError{Pos: token.Pos, Msg: fmt.Sprintf("expected %s, got %s", tokenType, describe(token))}
+	}
+	p.consumeToken()
+	return token, nil
+}
+
+// unexpected reports the current token as out of place, or as unrecognized
+// if the lexer could not classify it.
+func (p *Parser) unexpected() error {
+	if p.curToken.Type == "UNKNOWN" && strings.HasPrefix(p.curToken.Value, `"`) {
+		return &lexer.LexError{Pos: p.curToken.Pos, Msg: "unterminated string"}
+	}
+	if p.curToken.Type == "UNKNOWN" {
+		return &lexer.LexError{Pos: p.curToken.Pos, Msg: fmt.Sprintf("unrecognized token %q", p.curToken.Value)}
+	}
+	return &ParseError{Pos: p.curToken.Pos, Msg: fmt.Sprintf("unexpected %s", describe(p.curToken))}
+}
+
+// describe names a token for error messages.
+func describe(token lexer.Token) string {
+	switch token.Type {
+	case "EOF":
+		return "end of input"
+	case "NEWLINE":
+		if token.Value == ";" {
+			return `token ";"`
+		}
+		return "end of line"
+	default:
+		return fmt.Sprintf("token %q", token.Value)
+	}
+}
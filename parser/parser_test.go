@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"testing"
+
+	"turtle/lexer"
+)
+
+// parseAll parses every statement of src, returning the statements that
+// parsed and the errors of the ones that did not.
+func parseAll(src string) (stmts []string, errs []error) {
+	p := NewParser(lexer.NewLexerFromString(src))
+	for {
+		stmt, err := p.ParseStatement()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if stmt == nil {
+			return stmts, errs
+		}
+		stmts = append(stmts, stmt.String())
+	}
+}
+
+func TestRecoveryFromUnclosedParen(t *testing.T) {
+	tests := []struct {
+		src   string
+		stmts []string       // Statements parsed after the error
+		pos   lexer.Position // Position of the only error
+	}{
+		{
+			src:   "x = (1 + 2\ny = 3\nprint(\"after\")",
+			stmts: []string{`print("after")`},
+			pos:   lexer.Position{Line: 2, Column: 1},
+		},
+		{
+			src:   "xs = [1, 2\ny = 3\nz = 4\nz",
+			stmts: []string{"z = 4", "z"},
+			pos:   lexer.Position{Line: 2, Column: 1},
+		},
+		{
+			// A line ending in a comma continues, so the skip carries on
+			src:   "print(1 +* 2,\n  3)\nprint(\"ok\")",
+			stmts: []string{`print("ok")`},
+			pos:   lexer.Position{Line: 1, Column: 10},
+		},
+	}
+	for _, test := range tests {
+		stmts, errs := parseAll(test.src)
+		if len(errs) != 1 {
+			t.Errorf("%q: got errors %v, want one", test.src, errs)
+			continue
+		}
+		if err, ok := errs[0].(lexer.Error); !ok || err.Position() != test.pos {
+			t.Errorf("%q: got %v, want an error at %v", test.src, errs[0], test.pos)
+		}
+		if len(stmts) != len(test.stmts) {
+			t.Errorf("%q: parsed %q, want %q", test.src, stmts, test.stmts)
+			continue
+		}
+		for i := range stmts {
+			if stmts[i] != test.stmts[i] {
+				t.Errorf("%q: parsed %q, want %q", test.src, stmts, test.stmts)
+				break
+			}
+		}
+	}
+}
+
+func TestMultilineParensStillContinue(t *testing.T) {
+	stmts, errs := parseAll("x = (1 +\n  2)\nprint(x,\n  [3,\n   4])\ny = 5")
+	if len(errs) != 0 {
+		t.Fatalf("got errors %v", errs)
+	}
+	if len(stmts) != 3 {
+		t.Errorf("parsed %q, want three statements", stmts)
+	}
+}
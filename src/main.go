@@ -2,263 +2,397 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
-	"unicode"
+
+	"turtle/ast"
+	"turtle/interp"
+	"turtle/lexer"
+	"turtle/parser"
 )
 
-// Token represents a token in the input stream.
-type Token struct {
-	Type  string // Type of the token (e.g., "NUMBER", "PLUS", "IDENT", "ASSIGN", "EOF")
-	Value string // Value of the token (e.g., "42", "+", "x", "=")
-}
+// runREPL reads statements from stdin one line at a time and evaluates each
+// as soon as it is entered, until "quit" or the end of input. A line with
+// unbalanced parentheses is continued on the next line.
+func runREPL(useVM bool) {
+	interpreter := interp.NewInterpreter()
+	interpreter.VM = useVM
+	interpreter.OnError = func(err error) {
+		fmt.Println(err)
+	}
+	lex := lexer.NewLexerFromString("")
+	p := parser.NewParser(lex)
 
-// Lexer scans the input string and produces tokens.
-type Lexer struct {
-	scanner *bufio.Scanner
-	tokens  []Token
+	input := bufio.NewScanner(os.Stdin)
+	for {
+		if lex.Depth() > 0 {
+			fmt.Print("... ")
+		} else {
+			fmt.Print("> ")
+		}
+		if !input.Scan() {
+			// End of input (Ctrl-D)
+			fmt.Println()
+			return
+		}
+
+		line := input.Text()
+		if lex.Depth() == 0 && strings.TrimSpace(line) == "quit" {
+			return
+		}
+
+		p.Feed(line)
+		if lex.Depth() > 0 {
+			// Wait for the closing parenthesis
+			continue
+		}
+		if err := interpreter.Run(context.Background(), p); err != nil {
+			fmt.Println(err)
+		}
+	}
 }
 
-// NewLexer creates a new lexer with the given input file.
-func NewLexer(filename string) (*Lexer, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// runBatch evaluates each line read from in as soon as it arrives, like the
+// REPL but without prompts and with errors going to stderr, so Turtle can be
+// used as a filter in a pipeline. It returns false if any line failed.
+func runBatch(in io.Reader, out io.Writer, useVM bool) bool {
+	ok := true
+	interpreter := interp.NewInterpreter()
+	interpreter.VM = useVM
+	interpreter.Out = out
+	interpreter.OnError = func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		ok = false
 	}
-	defer file.Close()
+	lex := lexer.NewLexerFromString("")
+	p := parser.NewParser(lex)
+
+	input := bufio.NewScanner(in)
+	for more := true; more; {
+		more = input.Scan()
+		if more {
+			p.Feed(input.Text())
+			if lex.Depth() > 0 {
+				// Wait for the closing parenthesis
+				continue
+			}
+		}
 
-	scanner := bufio.NewScanner(file)
-	lexer := &Lexer{
-		scanner: scanner,
-		tokens:  make([]Token, 0),
+		// At the end of input this reports any unfinished statement
+		if err := interpreter.Run(context.Background(), p); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
 	}
-	lexer.tokenizeInput()
-	return lexer, nil
-}
 
-// tokenizeInput scans the input file and tokenizes the input.
-func (l *Lexer) tokenizeInput() {
-	for l.scanner.Scan() {
-		line := l.scanner.Text()
-		tokens := l.tokenizeLine(line)
-		l.tokens = append(l.tokens, tokens...)
+	// Input that could not be read, such as a line too long to scan, counts
+	// as a failure rather than an early end of input
+	if err := input.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		return false
 	}
+	return ok
 }
 
-// tokenizeLine tokenizes a single line of input.
-func (l *Lexer) tokenizeLine(line string) []Token {
-	tokens := make([]Token, 0)
-	scanner := bufio.NewScanner(strings.NewReader(line))
-	scanner.Split(bufio.ScanWords)
-
-	for scanner.Scan() {
-		tokenText := scanner.Text()
-		tokenType := l.getTokenType(tokenText)
-		token := Token{Type: tokenType, Value: tokenText}
-		tokens = append(tokens, token)
+// runTests runs every .t file in dir in an interpreter of its own, printing
+// the failing statements of each file to out and then how many files passed
+// and failed. What the files print themselves is discarded. It returns false
+// if any file failed.
+func runTests(dir string, useVM bool, out io.Writer) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(out, "Error reading directory: %v\n", err)
+		return false
 	}
 
-	return tokens
-}
+	passed, failed := 0, 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".t" {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		ok := true
+		report := func(err error) {
+			fmt.Fprintf(out, "FAIL %s: %v\n", filename, err)
+			ok = false
+		}
 
-// getTokenType determines the type of the token.
-func (l *Lexer) getTokenType(tokenText string) string {
-	switch tokenText {
-	case "+":
-		return "PLUS"
-	case "-":
-		return "MINUS"
-	case "*":
-		return "MULTIPLY"
-	case "/":
-		return "DIVIDE"
-	case "=":
-		return "ASSIGN"
-	case "(":
-		return "LPAREN"
-	case ")":
-		return "RPAREN"
-	default:
-		if _, err := strconv.Atoi(tokenText); err == nil {
-			return "NUMBER"
-		} else if unicode.IsLetter(rune(tokenText[0])) {
-			return "IDENT"
+		if lex, err := lexer.NewLexerFromFile(filename); err != nil {
+			report(err)
+		} else {
+			interpreter := interp.NewInterpreter()
+			interpreter.VM = useVM
+			interpreter.Out = io.Discard
+			interpreter.File = filename
+			interpreter.OnError = report
+			if err := interpreter.Run(context.Background(), parser.NewParser(lex)); err != nil {
+				report(err)
+			}
 		}
-	}
-	return "UNKNOWN"
-}
 
-// NextToken returns the next token in the input stream.
-func (l *Lexer) NextToken() Token {
-	if len(l.tokens) == 0 {
-		return Token{Type: "EOF", Value: ""}
+		if ok {
+			fmt.Fprintf(out, "ok   %s\n", filename)
+			passed++
+		} else {
+			failed++
+		}
 	}
-	token := l.tokens[0]
-	l.tokens = l.tokens[1:]
-	return token
+
+	fmt.Fprintf(out, "%d passed, %d failed\n", passed, failed)
+	return failed == 0
 }
 
-// Parser represents a recursive descent parser.
-type Parser struct {
-	lexer     *Lexer
-	curToken  Token
-	variables map[string]int // Map of variable name to variable value
+// isTerminal reports whether f is connected to a terminal rather than a pipe
+// or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
-// NewParser creates a new parser with the given lexer.
-func NewParser(lexer *Lexer) *Parser {
-	parser := &Parser{
-		lexer:     lexer,
-		variables: make(map[string]int),
+// explain prints the value of a variable and the expression it was last
+// assigned from.
+func explain(interpreter *interp.Interpreter, name string) {
+	value, source, ok := interpreter.Explain(name)
+	if !ok {
+		fmt.Printf("%s is not defined\n", name)
+		return
+	}
+	fmt.Printf("%s = %s\n", name, value.String())
+	if source != "" {
+		fmt.Printf("  assigned from: %s\n", source)
 	}
-	parser.consumeToken() // Initialize current token
-	return parser
 }
 
-// consumeToken advances to the next token in the input stream.
-func (p *Parser) consumeToken() {
-	p.curToken = p.lexer.NextToken()
+// source is a program to run, read from a file, standard input or -e.
+type source struct {
+	name string    // Name to report errors under
+	file string    // Path of the file to resolve imports against, if it is one
+	r    io.Reader // Source code
 }
 
-// parseStatement parses a statement (either variable assignment or expression).
-func (p *Parser) parseStatement() {
-	switch p.curToken.Type {
-	case "IDENT":
-		// Variable assignment
-		varName := p.curToken.Value
-		p.consumeToken() // Consume variable name
-		if p.curToken.Type == "ASSIGN" {
-			p.consumeToken() // Consume ASSIGN token
-			value := p.parseExpression()
-			p.variables[varName] = value
-		} else {
-			// If no ASSIGN token, treat it as an expression
-			// Print the result
-			fmt.Println(p.evaluateExpression(varName))
+// openSources opens the programs given on the command line: the -e source if
+// there is one, and otherwise each named file, with "-" meaning standard
+// input. The files stay open until closeSources is called.
+func openSources(names []string, inline string) ([]source, error) {
+	if inline != "" {
+		return []source{{name: "-e", r: strings.NewReader(inline)}}, nil
+	}
+	sources := make([]source, 0, len(names))
+	for _, name := range names {
+		if name == "-" {
+			sources = append(sources, source{name: name, r: os.Stdin})
+			continue
 		}
-	default:
-		// Expression statement
-		fmt.Println(p.parseExpression())
+		f, err := os.Open(name)
+		if err != nil {
+			closeSources(sources)
+			return nil, err
+		}
+		sources = append(sources, source{name: name, file: name, r: f})
 	}
+	return sources, nil
 }
 
-// parseExpression parses an expression.
-func (p *Parser) parseExpression() int {
-	// Parse the first term
-	left := p.parseTerm()
-
-	for p.curToken.Type == "PLUS" || p.curToken.Type == "MINUS" || p.curToken.Type == "MULTIPLY" || p.curToken.Type == "DIVIDE" {
-		// Store the operator
-		operator := p.curToken.Type
-		p.consumeToken()
-
-		// Parse the next term
-		right := p.parseTerm()
-
-		// Perform the operation based on the operator
-		switch operator {
-		case "PLUS":
-			left += right
-		case "MINUS":
-			left -= right
-		case "MULTIPLY":
-			left *= right
-		case "DIVIDE":
-			if right == 0 {
-				panic("Division by zero")
-			}
-			left /= right
-		}
-
-		// Check for a right parenthesis to handle parentheses
-		if p.curToken.Type == "RPAREN" {
-			return left
+// closeSources closes the files opened by openSources.
+func closeSources(sources []source) {
+	for _, src := range sources {
+		if f, ok := src.r.(*os.File); ok && f != os.Stdin {
+			f.Close()
 		}
 	}
-
-	return left
 }
 
-// parseTerm parses a term (number, variable reference, or parentheses expression).
-func (p *Parser) parseTerm() int {
-	switch p.curToken.Type {
-	case "NUMBER":
-		// Parse the number
-		number, err := strconv.Atoi(p.curToken.Value)
-		if err != nil {
-			panic("Failed to parse number")
+// runSources runs each source in order against the same interpreter, so a
+// later source can use anything an earlier one defined. A failing statement
+// is reported to errOut under the name of its source and the rest of the
+// source still runs; failed reports whether any did. A source that cannot be
+// read stops the run with an error.
+func runSources(interpreter *interp.Interpreter, sources []source, errOut io.Writer) (failed bool, err error) {
+	for _, src := range sources {
+		lex := lexer.NewLexer(src.r)
+		if err := lex.Err(); err != nil {
+			return failed, fmt.Errorf("%s: %v", src.name, err)
 		}
 
-		// Consume the NUMBER token
-		p.consumeToken()
-
-		return number
-	case "IDENT":
-		// Variable reference
-		varName := p.curToken.Value
-		p.consumeToken() // Consume variable name
-
-		// Check if variable exists
-		value, ok := p.variables[varName]
-		if !ok {
-			panic(fmt.Sprintf("Undefined variable: %s", varName))
+		// Report failing statements and carry on with the rest of the source
+		interpreter.OnError = func(err error) {
+			fmt.Fprintf(errOut, "%s: %v\n", src.name, err)
+			failed = true
 		}
 
-		return value
-	case "LPAREN":
-		// Consume the left parenthesis
-		p.consumeToken()
+		// Imports are resolved next to the file, or in the working directory
+		// for standard input and -e
+		interpreter.File = src.file
 
-		// Parse the expression inside the parentheses
-		result := p.parseExpression()
+		if err := interpreter.Run(context.Background(), parser.NewParser(lex)); err != nil {
+			return failed, fmt.Errorf("%s: %v", src.name, err)
+		}
+	}
+	return failed, nil
+}
 
-		// Ensure a matching right parenthesis
-		if p.curToken.Type != "RPAREN" {
-			panic("Expected RPAREN")
+// dumpTokens prints every token left in lex to out, one per line after its
+// position.
+func dumpTokens(lex *lexer.Lexer, out io.Writer) {
+	for {
+		token := lex.NextToken()
+		fmt.Fprintf(out, "%d:%d\t%s\n", token.Pos.Line, token.Pos.Column, token)
+		if token.Type == "EOF" {
+			return
 		}
+	}
+}
 
-		// Consume the right parenthesis
-		p.consumeToken()
+// dumpAST prints the syntax tree of every statement left in lex to out,
+// reporting statements that fail to parse. It returns false if any did.
+func dumpAST(name string, lex *lexer.Lexer, out io.Writer) bool {
+	ok := true
+	p := parser.NewParser(lex)
+	for {
+		stmt, err := p.ParseStatement()
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", name, err)
+			ok = false
+			continue
+		}
+		if stmt == nil {
+			return ok
+		}
+		ast.Fprint(out, stmt)
+	}
+}
 
-		return result
+// saveDrawing renders the turtle's drawing to filename, as SVG or PNG
+// depending on its extension.
+func saveDrawing(turtle *interp.Turtle, filename string) error {
+	var render func(io.Writer) error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".svg":
+		render = turtle.WriteSVG
+	case ".png":
+		render = turtle.WritePNG
 	default:
-		panic("Unexpected token")
+		return fmt.Errorf("%s: unsupported image format, use .svg or .png", filename)
 	}
-}
 
-// evaluateExpression evaluates an expression with variable references.
-func (p *Parser) evaluateExpression(varName string) int {
-	value, ok := p.variables[varName]
-	if !ok {
-		panic(fmt.Sprintf("Undefined variable: %s", varName))
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := render(f); err != nil {
+		f.Close()
+		return err
 	}
-	return value
+	return f.Close()
 }
 
 func main() {
-	// Check if a filename is provided
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go inputfile")
+	explainVar := flag.String("explain", "", "after running, show the value of `VAR` and the expression it came from")
+	useVM := flag.Bool("vm", false, "run programs on the bytecode VM instead of the tree-walking interpreter")
+	outFile := flag.String("out", "", "after running, save the turtle's drawing to `FILE`, an .svg or .png image")
+	inline := flag.String("e", "", "run the program `SOURCE` given on the command line instead of a file, with its statements separated by ;")
+	showTokens := flag.Bool("tokens", false, "print the tokens of the program instead of running it")
+	showAST := flag.Bool("ast", false, "print the syntax tree of the program instead of running it")
+	flag.Parse()
+
+	// The tokens or tree of a piped program can be asked for without naming
+	// standard input
+	names := flag.Args()
+	if *inline == "" && len(names) == 0 && (*showTokens || *showAST) {
+		names = []string{"-"}
+	}
+
+	if *showTokens || *showAST {
+		sources, err := openSources(names, *inline)
+		if err != nil {
+			fmt.Printf("Error opening file: %v\n", err)
+			os.Exit(1)
+		}
+		defer closeSources(sources)
+
+		failed := false
+		for _, src := range sources {
+			lex := lexer.NewLexer(src.r)
+			if err := lex.Err(); err != nil {
+				fmt.Printf("%s: %v\n", src.name, err)
+				os.Exit(1)
+			}
+			if *showTokens {
+				dumpTokens(lex, os.Stdout)
+			} else if !dumpAST(src.name, lex, os.Stdout) {
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "turtle repl" always starts an interactive session
+	if *inline == "" && flag.NArg() == 1 && flag.Arg(0) == "repl" {
+		runREPL(*useVM)
+		return
+	}
+
+	// "turtle test dir" runs the test files in dir, or in the working
+	// directory if it is left out
+	if *inline == "" && flag.NArg() <= 2 && flag.Arg(0) == "test" {
+		dir := "."
+		if flag.NArg() == 2 {
+			dir = flag.Arg(1)
+		}
+		if !runTests(dir, *useVM, os.Stdout) {
+			os.Exit(1)
+		}
 		return
 	}
 
-	filename := os.Args[1]
+	// Without a filename, start an interactive session, or evaluate piped
+	// input line by line
+	if *inline == "" && len(names) == 0 {
+		if isTerminal(os.Stdin) {
+			runREPL(*useVM)
+		} else if !runBatch(os.Stdin, os.Stdout, *useVM) {
+			os.Exit(1)
+		}
+		return
+	}
 
-	lexer, err := NewLexer(filename)
+	// Every file is evaluated in order against the same variables
+	sources, err := openSources(names, *inline)
 	if err != nil {
 		fmt.Printf("Error opening file: %v\n", err)
-		return
+		os.Exit(1)
 	}
+	defer closeSources(sources)
 
-	parser := NewParser(lexer)
+	interpreter := interp.NewInterpreter()
+	interpreter.VM = *useVM
+	// Errors go to stderr, so that results piped on are not mixed with them
+	failed, err := runSources(interpreter, sources, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	// Parse statements
-	for {
-		parser.parseStatement()
-		if parser.curToken.Type == "EOF" {
-			break
+	if *explainVar != "" {
+		explain(interpreter, *explainVar)
+	}
+
+	if *outFile != "" {
+		if err := saveDrawing(interpreter.Turtle, *outFile); err != nil {
+			fmt.Printf("Error saving drawing: %v\n", err)
+			os.Exit(1)
 		}
 	}
+
+	if failed {
+		os.Exit(1)
+	}
 }
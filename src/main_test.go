@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"turtle/interp"
+	"turtle/lexer"
+)
+
+func TestRunSourcesSharesVariables(t *testing.T) {
+	var out, errOut bytes.Buffer
+	interpreter := interp.NewInterpreter()
+	interpreter.Out = &out
+	sources := []source{
+		{name: "defs.tl", r: strings.NewReader("width = 20\nfn area(h) { return width * h }\n")},
+		{name: "main.tl", r: strings.NewReader("area(3)\nwidth + 1\n")},
+	}
+
+	failed, err := runSources(interpreter, sources, &errOut)
+	if err != nil || failed {
+		t.Fatalf("got failed = %v, %v; errors:\n%s", failed, err, errOut.String())
+	}
+	if want := "60\n21\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunSourcesReportsFailuresAndCarriesOn(t *testing.T) {
+	var out, errOut bytes.Buffer
+	interpreter := interp.NewInterpreter()
+	interpreter.Out = &out
+	sources := []source{
+		{name: "first.tl", r: strings.NewReader("x = 1\n1 / 0\ny = 2\n")},
+		{name: "second.tl", r: strings.NewReader("missing\nx + y\n")},
+	}
+
+	failed, err := runSources(interpreter, sources, &errOut)
+	if err != nil || !failed {
+		t.Fatalf("got failed = %v, %v, want a failure", failed, err)
+	}
+	if out.String() != "3\n" {
+		t.Errorf("printed %q, want %q", out.String(), "3\n")
+	}
+	reports := strings.Split(strings.TrimSpace(errOut.String()), "\n")
+	if len(reports) != 2 || !strings.HasPrefix(reports[0], "first.tl: runtime error at line 2") || !strings.HasPrefix(reports[1], "second.tl: runtime error at line 1") {
+		t.Errorf("got error reports %q", reports)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		var out bytes.Buffer
+		in := strings.NewReader("x = 5\nx * 2\nprint(\"sum:\",\n  x + 1)\n")
+		if !runBatch(in, &out, useVM) {
+			t.Errorf("vm=%v: reported a failure", useVM)
+		}
+		if want := "10\nsum: 6\n"; out.String() != want {
+			t.Errorf("vm=%v: printed %q, want %q", useVM, out.String(), want)
+		}
+	}
+}
+
+func TestRunBatchFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // What is printed before the failure
+	}{
+		{name: "runtime error", in: "1\n1 / 0\n2\n", want: "1\n2\n"},
+		{name: "unfinished statement", in: "1\nprint(2,\n", want: "1\n"},
+		{name: "line too long", in: "1\nx = \"" + strings.Repeat("a", 70000) + "\"\n2\n", want: "1\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if runBatch(strings.NewReader(test.in), &out, false) {
+			t.Errorf("%s: reported success", test.name)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: printed %q, want %q", test.name, out.String(), test.want)
+		}
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pass.t":    "x = 2\nprint(\"ignored\")\nassert(x * 2 == 4)\n",
+		"fail.t":    "assert(1 > 2, \"one is not more than two\")\n",
+		"notes.txt": "not a test\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, useVM := range []bool{false, true} {
+		var out bytes.Buffer
+		if runTests(dir, useVM, &out) {
+			t.Errorf("vm=%v: reported success with a failing file", useVM)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("vm=%v: printed %q, want three lines", useVM, out.String())
+		}
+		if want := "FAIL " + filepath.Join(dir, "fail.t") + ": "; !strings.HasPrefix(lines[0], want) || !strings.Contains(lines[0], "one is not more than two") {
+			t.Errorf("vm=%v: got %q, want the failed assertion", useVM, lines[0])
+		}
+		if want := "ok   " + filepath.Join(dir, "pass.t"); lines[1] != want {
+			t.Errorf("vm=%v: got %q, want %q", useVM, lines[1], want)
+		}
+		if want := "1 passed, 1 failed"; lines[2] != want {
+			t.Errorf("vm=%v: got %q, want %q", useVM, lines[2], want)
+		}
+	}
+}
+
+func TestRunTestsAllPassing(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pass.t"), []byte("assert(true)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if !runTests(dir, false, &out) {
+		t.Errorf("reported a failure:\n%s", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "1 passed, 0 failed\n") {
+		t.Errorf("printed %q, want 1 passed, 0 failed", out.String())
+	}
+}
+
+func TestDumpTokens(t *testing.T) {
+	var out bytes.Buffer
+	dumpTokens(lexer.NewLexerFromString("x = 2 * (3 + 4)"), &out)
+	want := `1:1	IDENT "x"
+1:3	ASSIGN "="
+1:5	NUMBER "2"
+1:7	MULTIPLY "*"
+1:9	LPAREN "("
+1:10	NUMBER "3"
+1:12	PLUS "+"
+1:14	NUMBER "4"
+1:15	RPAREN ")"
+1:16	NEWLINE "\n"
+1:16	EOF
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestDumpAST(t *testing.T) {
+	var out bytes.Buffer
+	if dumpAST("-e", lexer.NewLexerFromString("x = 1\ny = (\nz"), &out) {
+		t.Error("reported success for a statement that fails to parse")
+	}
+	want := "AssignStmt x  (line 1, col 1)\n  IntLit 1  (line 1, col 5)\n-e: parse error at line 3, col 2: "
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("got\n%s\nwant it to start with\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,62 @@
+// Package turtle embeds the Turtle language in a Go program.
+//
+// Eval and EvalReader run a program on its own. For more control,
+// NewInterpreter creates an interpreter whose variables persist from one
+// EvalString call to the next, which the host can pre-define with Define and
+// extend with Go functions through RegisterBuiltin:
+//
+//	t := turtle.NewInterpreter()
+//	t.Define("width", turtle.Int(640))
+//	t.RegisterBuiltin("double", func(args []turtle.Value) (turtle.Value, error) {
+//		return args[0].(turtle.Int) * 2, nil
+//	})
+//	v, err := t.EvalString("double(width)")
+package turtle
+
+import (
+	"io"
+
+	"turtle/interp"
+)
+
+// Interpreter runs Turtle programs, keeping their variables between runs.
+type Interpreter = interp.Interpreter
+
+// Value is a value of any kind a Turtle program can compute.
+type Value = interp.Value
+
+// The kinds of value a host can pass in and get back.
+type (
+	Int    = interp.Int
+	Float  = interp.Float
+	Bool   = interp.Bool
+	String = interp.String
+	Nil    = interp.Nil
+)
+
+// NewInterpreter creates an interpreter with only the builtin functions
+// defined, printing to os.Stdout.
+func NewInterpreter() *Interpreter {
+	return interp.NewInterpreter()
+}
+
+// Eval runs src in a new interpreter and returns the value of its last
+// expression statement. Nothing the program prints is shown; to see it, use
+// an interpreter from NewInterpreter, whose Out can be set.
+func Eval(src string) (Value, error) {
+	return quietInterpreter().EvalString(src)
+}
+
+// EvalReader runs the program read from r, such as os.Stdin, in a new
+// interpreter and returns the value of its last expression statement. Like
+// Eval, it shows nothing the program prints.
+func EvalReader(r io.Reader) (Value, error) {
+	return quietInterpreter().EvalReader(r)
+}
+
+// quietInterpreter creates an interpreter that discards what it prints.
+func quietInterpreter() *Interpreter {
+	t := NewInterpreter()
+	t.Out = io.Discard
+	return t
+}
@@ -0,0 +1,110 @@
+package turtle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{src: "42", want: Int(42)},
+		{src: "x = 2 * (3 + 4)\nx", want: Int(14)},
+		{src: "7 / 2", want: Float(3.5)},
+		{src: `"tur" + "tle"`, want: String("turtle")},
+		{src: "1 < 2", want: Bool(true)},
+		{src: "x = 1", want: Nil{}},
+	}
+	for _, test := range tests {
+		got, err := Eval(test.src)
+		if err != nil || got != test.want {
+			t.Errorf("Eval(%q) = %v, %v, want %v", test.src, got, err, test.want)
+		}
+	}
+}
+
+func TestEvalPrintsNothing(t *testing.T) {
+	// Swap stdout for a pipe to see whether anything is written to it
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	_, evalErr := Eval("42\nprint(\"hello\")")
+	_, readerErr := EvalReader(strings.NewReader("43"))
+	os.Stdout = stdout
+	w.Close()
+
+	var printed bytes.Buffer
+	printed.ReadFrom(r)
+	if evalErr != nil || readerErr != nil {
+		t.Fatal(evalErr, readerErr)
+	}
+	if printed.Len() != 0 {
+		t.Errorf("printed %q, want nothing", printed.String())
+	}
+}
+
+func TestEvalReader(t *testing.T) {
+	got, err := EvalReader(strings.NewReader("fn sq(x) { return x * x }\nsq(9)\n"))
+	if err != nil || got != Int(81) {
+		t.Errorf("got %v, %v, want 81", got, err)
+	}
+}
+
+func TestDefine(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Out = io.Discard
+	interp.Define("width", Int(640))
+	interp.Define("title", String("box"))
+
+	got, err := interp.EvalString(`title + ": " + "w"`)
+	if err != nil || got != String("box: w") {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if got, err = interp.EvalString("width / 2"); err != nil || got != Int(320) {
+		t.Errorf("width / 2 = %v, %v, want 320", got, err)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	interp := NewInterpreter()
+	var out bytes.Buffer
+	interp.Out = &out
+	interp.Define("width", Int(640))
+	interp.RegisterBuiltin("double", func(args []Value) (Value, error) {
+		return args[0].(Int) * 2, nil
+	})
+	interp.RegisterBuiltin("log", func(args []Value) (Value, error) {
+		return nil, nil
+	})
+	failure := errors.New("out of ink")
+	interp.RegisterBuiltin("fail", func(args []Value) (Value, error) {
+		return nil, failure
+	})
+
+	if got, err := interp.EvalString("double(width)"); err != nil || got != Int(1280) {
+		t.Errorf("double(width) = %v, %v, want 1280", got, err)
+	}
+
+	// A builtin that returns nil returns nil to the program, printing nothing
+	out.Reset()
+	if got, err := interp.EvalString("log(1)"); err != nil || got != (Nil{}) {
+		t.Errorf("log(1) = %v, %v, want nil", got, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("printed %q, want nothing", out.String())
+	}
+
+	_, err := interp.EvalString("fail()")
+	if !errors.Is(err, failure) || !strings.Contains(err.Error(), "line 1, col 5: fail: out of ink") {
+		t.Errorf("fail() gave %v, want a runtime error wrapping %v", err, failure)
+	}
+}